@@ -24,10 +24,15 @@ THE SOFTWARE.
 package actor
 
 import (
+	"context"
+	"fmt"
 	"github.com/mafei198/gactor/api"
+	"github.com/mafei198/gactor/clock"
+	rpcproto "github.com/mafei198/gactor/rpc_proto"
 	"github.com/mafei198/goslib/gen_server"
 	"github.com/mafei198/goslib/logger"
 	"github.com/mafei198/goslib/misc"
+	"sync/atomic"
 	"time"
 )
 
@@ -43,12 +48,25 @@ type Behavior interface {
 	OnStop(reason string) error
 }
 
+// messages sent to actors but not handled yet
+var mailboxBacklog int64
+
+func MailboxBacklog() int64 {
+	return atomic.LoadInt64(&mailboxBacklog)
+}
+
 func Call(actorId string, msg interface{}, options ...*gen_server.Option) (interface{}, error) {
 	server, err := GetActor(actorId)
 	if err != nil {
 		return nil, err
 	}
-	return server.Call(msg, options...)
+	atomic.AddInt64(&mailboxBacklog, 1)
+	rsp, err := server.Call(msg, options...)
+	atomic.AddInt64(&mailboxBacklog, -1)
+	if err == errMigrated {
+		return forwardCall(actorId, msg, options...)
+	}
+	return rsp, err
 }
 
 func Cast(actorId string, msg interface{}) error {
@@ -56,7 +74,18 @@ func Cast(actorId string, msg interface{}) error {
 	if err != nil {
 		return err
 	}
-	return server.Cast(msg)
+	return castActor(server, msg)
+}
+
+// Every cast handled by Server.HandleCast is sent here, so it's counted in
+// mailboxBacklog once it's enqueued.
+func castActor(server *gen_server.GenServer, msg interface{}) error {
+	atomic.AddInt64(&mailboxBacklog, 1)
+	err := server.Cast(msg)
+	if err != nil {
+		atomic.AddInt64(&mailboxBacklog, -1)
+	}
+	return err
 }
 
 func Wrap(id string, handler WrapHandler, options ...*gen_server.Option) (interface{}, error) {
@@ -80,7 +109,17 @@ type Server struct {
 	ActiveAt  int64
 	Processed int64
 
-	tickers []*time.Ticker
+	tickers     []*serverTicker
+	asleep      bool
+	restarts    []time.Time     // restarts within Factory.RestartWindow
+	monitors    map[string]bool // watcher id => linked
+	topics      map[string]bool // subscribed topics
+	timers      map[TimerRef]*actorTimer
+	migratedTo  string      // node id migrated to
+	forwards    chan func() // forwarding of migrated actor
+	crashed     bool        // stopping for panic, state not saved
+	seq         uint64      // last applied event of event sourcing
+	snapshotSeq uint64
 }
 
 type requestParams struct{ request *api.Request }
@@ -92,73 +131,186 @@ func (ins *Server) Init(args []interface{}) (err error) {
 	ins.Factory = args[1].(*Factory)
 	ins.Actor = ins.Factory.Constructor()
 	ins.PlayerId = ins.Meta.Uuid
-	ins.ActiveAt = time.Now().Unix()
-	ins.StartTicker(time.Minute, &activeCheckParams{})
+	ins.ActiveAt = clock.Now().Unix()
+	ins.StartTicker(ins.Factory.idleCheckInterval(), &activeCheckParams{})
+	if err = ins.loadState(); err != nil {
+		return err
+	}
+	if err = ins.restoreMigratedState(); err != nil {
+		return err
+	}
+	if err = ins.restoreTimers(); err != nil {
+		return err
+	}
 	return ins.Actor.OnStart(ins)
 }
 
 type activeCheckParams struct{}
 
-func (ins *Server) HandleCall(req *gen_server.Request) (interface{}, error) {
-	switch req.Msg.(type) {
-	case *activeCheckParams:
-		if time.Now().Unix()-ins.ActiveAt >= ExpireDuration {
-			if !ins.Meta.Dispatch.IsDaemon {
-				MarkActorSleep(ins.PlayerId)
-			}
+func (ins *Server) HandleCall(req *gen_server.Request) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, ins.onPanic(r)
+		}
+	}()
+	msg := req.Msg
+	if tick, ok := msg.(*tickParams); ok {
+		if ins.asleep {
+			return nil, nil
 		}
+		msg = tick.msg
+	} else {
+		// sleep requested but not handled by manager yet
+		ins.wake()
+	}
+	if ins.migratedTo != "" {
+		return ins.handleMigratedCall(msg)
+	}
+	switch params := msg.(type) {
+	case *activeCheckParams:
+		ins.checkIdle()
 		return nil, nil
-	default:
-		ins.ActiveAt = time.Now().Unix()
-		handler, ok := ins.Factory.Route(req.Msg)
-		if !ok || handler == nil {
-			return nil, api.ErrRouteNotFound
+	case *migrateParams:
+		return nil, ins.handleMigrate(params.targetNodeId)
+	case *flushParams:
+		return nil, ins.saveState(false)
+	case *wrapParams:
+		return params.Handler(ins.Actor), nil
+	case *contextCallParams:
+		if err := params.ctx.Err(); err != nil {
+			return nil, err
 		}
-		request := api.NewLocalRequest(api.ReqCall, req.Msg)
-		return handler(request), nil
+		return ins.handleCall(params.msg)
+	default:
+		return ins.handleCall(msg)
+	}
+}
+
+func (ins *Server) handleCall(msg interface{}) (interface{}, error) {
+	atomic.StoreInt64(&ins.ActiveAt, clock.Now().Unix())
+	handler, ok := ins.Factory.RouteErr(msg)
+	if !ok {
+		return nil, api.ErrRouteNotFound
+	}
+	request := api.NewLocalRequest(api.ReqCall, msg)
+	request.Ctx = ins.Actor
+	rsp, err := handler(request)
+	if err != nil {
+		return nil, err
 	}
+	return rsp, nil
 }
 
 func (ins *Server) HandleCast(req *gen_server.Request) {
-	ins.ActiveAt = time.Now().Unix()
+	atomic.AddInt64(&mailboxBacklog, -1)
+	atomic.StoreInt64(&ins.ActiveAt, clock.Now().Unix())
+	defer func() {
+		if r := recover(); r != nil {
+			_ = ins.onPanic(r)
+		}
+	}()
+	if ins.migratedTo != "" {
+		ins.handleMigratedCast(req.Msg)
+		return
+	}
+	ins.wake()
 	switch params := req.Msg.(type) {
 	case *requestParams:
-		_ = ins.handleRequest(params)
-	case *wrapParams:
+		if err := ins.handleRequest(params); err != nil {
+			_ = params.request.ResponseError(err)
+		}
+	case *asyncWrapParams:
 		params.Handler(ins.Actor)
+	case *timerParams:
+		ins.fireTimer(params.ref)
+	case *wakeParams:
+		ins.wake()
 	default:
-		if handler, ok := ins.Factory.Route(req.Msg); ok && handler != nil {
-			request := api.NewLocalRequest(api.ReqCast, req.Msg)
-			handler(request)
-		} else {
-			logger.ERR("Msg: ", req.Msg, api.ErrRouteNotFound)
+		ins.handleCast(req.Msg)
+	}
+}
+
+func (ins *Server) handleCast(msg interface{}) {
+	if handler, ok := ins.Factory.RouteErr(msg); ok {
+		request := api.NewLocalRequest(api.ReqCast, msg)
+		request.Ctx = ins.Actor
+		if _, err := handler(request); err != nil {
+			logger.ERR("handle cast failed: ", misc.GetType(msg), err)
 		}
+	} else {
+		logger.ERR("Msg: ", msg, api.ErrRouteNotFound)
 	}
 }
 
 func (ins *Server) Terminate(reason string) error {
-	err := ins.Actor.OnStop(reason)
-	if err == nil {
-		for _, ticker := range ins.tickers {
-			ticker.Stop()
-		}
-		if !ins.Meta.Dispatch.IsDaemon {
-			ExpireMeta(ins.Meta.Uuid)
+	stopErr := ins.Actor.OnStop(reason)
+	var saveErr error
+	if stopErr == nil && ins.migratedTo == "" {
+		// state is owned by target node after migrated
+		if saveErr = ins.saveState(true); saveErr != nil {
+			logger.ERR("save state on terminate failed: ", ins.Meta.Uuid, saveErr)
 		}
 	}
-	return err
+	for _, ticker := range ins.tickers {
+		ticker.stop()
+	}
+	ins.tickers = nil
+	// Meta isn't expired if state isn't saved, actor stays placed on this node
+	// and reloads its last saved state when started again.
+	if stopErr == nil && saveErr == nil && !ins.Meta.Dispatch.IsDaemon && ins.migratedTo == "" {
+		ExpireMeta(ins.Meta.Uuid)
+	}
+	ins.unsubscribeAll()
+	ins.stopTimers()
+	if ins.migratedTo != "" {
+		close(ins.forwards)
+		ins.handoffMonitors()
+	} else if isDown(reason) {
+		ins.notifyDown(reason)
+		// Terminate may run inside actor manager, leave outside of it
+		go leaveGroups(ins.Meta.Uuid)
+	}
+	if stopErr != nil {
+		return stopErr
+	}
+	return saveErr
 }
 
 func (ins *Server) handleRequest(params *requestParams) error {
 	req := params.request
 	ins.Processed++
-	handler, ok := ins.Factory.Route(req.Params)
-	if !ok || handler == nil {
+	defer func() {
+		if r := recover(); r != nil {
+			// let remote caller know, then supervised by HandleCast
+			_ = req.ResponseError(api.NewError(api.ErrCodePanic, fmt.Sprint(r)))
+			panic(r)
+		}
+	}()
+	if req.Expired() {
+		logger.ERR("drop expired request: ", ins.Meta.Uuid, misc.GetType(req.Params))
+		return context.DeadlineExceeded
+	}
+	switch msg := req.Params.(type) {
+	case *rpcproto.MonitorActor:
+		ins.handleMonitor(msg)
+		return nil
+	case *rpcproto.ActorDown:
+		if ins.handleActorDown(msg) {
+			return nil
+		}
+	}
+	handler, ok := ins.Factory.RouteErr(req.Params)
+	if !ok {
 		logger.ERR("Route not found: ", misc.GetType(req.Params))
 		return api.ErrRouteNotFound
 	}
 	req.Ctx = ins.Actor
-	if rsp := handler(req); rsp != nil {
+	rsp, err := handler(req)
+	if err != nil {
+		logger.ERR("handle request failed: ", misc.GetType(req.Params), err)
+		return err
+	}
+	if rsp != nil {
 		return req.Response(rsp)
 	}
 	return nil
@@ -172,18 +324,34 @@ func (ins *Server) GetCategory() string {
 	return ins.Meta.Category
 }
 
+type serverTicker struct {
+	ticker clock.Ticker
+	done   chan struct{}
+}
+
+func (t *serverTicker) stop() {
+	t.ticker.Stop()
+	close(t.done)
+}
+
+type tickParams struct{ msg interface{} }
+
+// Ticks are skipped while actor is sleeping, ticker is stopped in Terminate
 func (ins *Server) StartTicker(duration time.Duration, msg interface{}) {
-	ticker := time.NewTicker(duration)
-	ins.tickers = append(ins.tickers, ticker)
+	t := &serverTicker{ticker: clock.NewTicker(duration), done: make(chan struct{})}
+	ins.tickers = append(ins.tickers, t)
 	category := ins.Meta.Category
 	actorId := ins.Meta.Uuid
 	go func() {
-		for range ticker.C {
-			if _, err := gen_server.Call(actorId, msg); err != nil {
-				logger.ERR("ticker failed: ", category, actorId, msg, err)
-				if err == gen_server.ErrNotExist {
-					break
+		for {
+			select {
+			case <-t.ticker.C():
+				_, err := gen_server.Call(actorId, &tickParams{msg: msg})
+				if err != nil && err != gen_server.ErrNotExist {
+					logger.ERR("ticker failed: ", category, actorId, msg, err)
 				}
+			case <-t.done:
+				return
 			}
 		}
 	}()
@@ -0,0 +1,21 @@
+package actor
+
+import "testing"
+
+func TestWrap(t *testing.T) {
+	startLocalNode(t)
+	factory, _ := newProbeFactory()
+	actorId := addActor(t, factory, false)
+	err := AsyncWrap(actorId, func(ctx interface{}) {
+		ctx.(*probeBehavior).count++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := Wrap(actorId, func(ctx interface{}) interface{} {
+		return ctx.(*probeBehavior).count
+	})
+	if err != nil || value.(int) != 1 {
+		t.Fatal("wrap failed: ", value, err)
+	}
+}
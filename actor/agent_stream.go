@@ -64,10 +64,12 @@ func (a *AgentStream) receiveLoop() error {
 		msg, err := pbmsg.Decode(in.Data)
 		if err != nil {
 			logger.ERR("AgentStream decode failed:", err)
+			a.responseError(in, api.NewError(api.ErrCodeDecode, err.Error()))
 			break
 		}
 		request := api.NewRequest(a, in.ReqType, in.ReqId, msg)
 		if err := Request(a.actorId, request); err != nil {
+			a.responseError(in, api.ToError(err, api.ErrCodeActorStart))
 			return err
 		}
 	}
@@ -82,7 +84,7 @@ func (a *AgentStream) GetActorId() string {
 	return a.actorId
 }
 
-func (a *AgentStream) SendData(reqId int32, data []byte) error {
+func (a *AgentStream) SendData(reqId int64, data []byte) error {
 	return a.stream.Send(&rpcproto.StreamAgentRsp{
 		ReqId:       reqId,
 		FromActorId: a.actorId,
@@ -90,6 +92,24 @@ func (a *AgentStream) SendData(reqId int32, data []byte) error {
 	})
 }
 
+func (a *AgentStream) SendError(reqId int64, err *api.Error) error {
+	return a.stream.Send(&rpcproto.StreamAgentRsp{
+		ReqId:       reqId,
+		FromActorId: a.actorId,
+		ErrCode:     err.Code,
+		ErrMsg:      err.Message,
+	})
+}
+
+func (a *AgentStream) responseError(in *rpcproto.StreamAgentMsg, err *api.Error) {
+	if in.ReqType != api.ReqCall {
+		return
+	}
+	if err := a.SendError(in.ReqId, err); err != nil {
+		logger.ERR("AgentStream send error failed: ", err)
+	}
+}
+
 func (a *AgentStream) Close(reason string) error {
 	a.closed = true
 	a.closeReason = reason
@@ -0,0 +1,124 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package actor
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/mafei198/gactor/api"
+	"github.com/mafei198/gactor/cluster"
+	rpcproto "github.com/mafei198/gactor/rpc_proto"
+	"github.com/mafei198/goslib/logger"
+	"github.com/mafei198/goslib/pbmsg"
+)
+
+func init() {
+	pbmsg.Register(func() proto.Message { return &rpcproto.BroadcastMsg{} })
+}
+
+// Cast msg to actors with one message per node. Actors not placed on an alive
+// node are skipped instead of dispatched, broadcast never starts them elsewhere.
+func CastMulti(actorIds []string, msg proto.Message) error {
+	data, err := pbmsg.Encode(msg)
+	if err != nil {
+		return err
+	}
+	nodeActors := map[string][]string{}
+	for _, actorId := range actorIds {
+		meta, err := placedMeta(actorId)
+		if err != nil {
+			logger.ERR("broadcast skip actor: ", actorId, err)
+			continue
+		}
+		if meta == nil {
+			continue
+		}
+		nodeActors[meta.NodeId] = append(nodeActors[meta.NodeId], actorId)
+	}
+	var lastErr error
+	for nodeId, ids := range nodeActors {
+		batch := &rpcproto.BroadcastMsg{ActorIds: ids, Data: data}
+		if err := sendBatch(nodeId, batch); err != nil {
+			logger.ERR("broadcast to node failed: ", nodeId, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// Meta of actor placed on an alive node, nil if not placed
+func placedMeta(actorId string) (*Meta, error) {
+	meta, err := lookupMeta(actorId)
+	if err == ErrActorMetaNotExists {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if meta.NodeId == "" {
+		return nil, nil
+	}
+	if _, ok := cluster.FindNode(meta.NodeId); !ok {
+		return nil, nil
+	}
+	return meta, nil
+}
+
+func sendBatch(nodeId string, batch *rpcproto.BroadcastMsg) error {
+	if nodeId == cluster.GetCurrentNodeId() {
+		deliverBatch(batch)
+		return nil
+	}
+	node, ok := cluster.FindNode(nodeId)
+	if !ok {
+		return errNodeNotFound
+	}
+	stream, err := GetStreamClient(node)
+	if err != nil {
+		return err
+	}
+	data, err := pbmsg.Encode(batch)
+	if err != nil {
+		return err
+	}
+	return stream.StreamClient.Send(&rpcproto.StreamAgentMsg{
+		ReqType:      api.ReqBroadcast,
+		Data:         data,
+		OriginNodeId: cluster.GetCurrentNodeId(),
+	})
+}
+
+// each actor decodes its own copy, handlers may modify it
+func deliverBatch(batch *rpcproto.BroadcastMsg) {
+	for _, actorId := range batch.ActorIds {
+		msg, err := pbmsg.Decode(batch.Data)
+		if err != nil {
+			logger.ERR("decode broadcast failed: ", err)
+			return
+		}
+		request := api.NewRequest(nil, api.ReqCast, 0, msg)
+		if err = Request(actorId, request); err != nil {
+			logger.ERR("deliver broadcast failed: ", actorId, err)
+		}
+	}
+}
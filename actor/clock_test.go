@@ -0,0 +1,201 @@
+package actor
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/mafei198/gactor/api"
+	"github.com/mafei198/gactor/clock"
+	rpcproto "github.com/mafei198/gactor/rpc_proto"
+	"github.com/mafei198/goslib/gen_server"
+	"github.com/mafei198/goslib/pbmsg"
+	"testing"
+	"time"
+)
+
+type sleepBehavior struct {
+	slept, woken int
+}
+
+func (b *sleepBehavior) OnStart(server *Server) error { return nil }
+func (b *sleepBehavior) OnStop(reason string) error   { return nil }
+func (b *sleepBehavior) OnSleep(server *Server) error { b.slept++; return nil }
+func (b *sleepBehavior) OnWake(server *Server) error  { b.woken++; return nil }
+
+func newFakeClock() *clock.Fake {
+	fake := clock.NewFake(time.Unix(1500000000, 0))
+	clock.Set(fake)
+	return fake
+}
+
+func TestIdleSleepAndWake(t *testing.T) {
+	fake := newFakeClock()
+	defer clock.Set(clock.Real)
+	behavior := &sleepBehavior{}
+	ins := &Server{
+		Meta:     &Meta{Uuid: "clock-test-idle", Dispatch: DefaultDispatch()},
+		Factory:  &Factory{IdleTimeout: time.Minute},
+		Actor:    behavior,
+		ActiveAt: fake.Now().Unix(),
+	}
+	fake.Advance(59 * time.Second)
+	ins.checkIdle()
+	if ins.asleep {
+		t.Fatal("slept before idle timeout")
+	}
+	fake.Advance(time.Second)
+	ins.checkIdle()
+	if !ins.asleep || behavior.slept != 1 {
+		t.Fatal("not slept after idle timeout")
+	}
+	ins.wake()
+	if ins.asleep || behavior.woken != 1 {
+		t.Fatal("not woken")
+	}
+}
+
+func TestRpcTimeoutByClock(t *testing.T) {
+	fake := newFakeClock()
+	defer clock.Set(clock.Real)
+	var err error
+	if server, err = gen_server.Start(serverName, new(RpcMgr)); err != nil {
+		t.Fatal(err)
+	}
+	defer gen_server.Stop(serverName, "test")
+	request := &RpcRequest{
+		StreamAgentMsg: &rpcproto.StreamAgentMsg{ReqId: genRpcReqId()},
+		CreatedAt:      fake.Now().UnixNano(),
+		Timeout:        time.Second,
+		done:           make(chan *rpcResult, 1),
+		index:          -1,
+	}
+	if err = AddRpcRequest(request); err != nil {
+		t.Fatal(err)
+	}
+	// wait request added
+	if _, err = gen_server.Call(serverName, &CheckTimeoutParams{}); err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(999 * time.Millisecond)
+	if _, err = gen_server.Call(serverName, &CheckTimeoutParams{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case result := <-request.done:
+		t.Fatal("timed out early: ", result.err)
+	default:
+	}
+	fake.Advance(time.Millisecond)
+	select {
+	case result := <-request.done:
+		if result.err != ErrTimeout {
+			t.Fatal("unexpected result: ", result.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("not timed out")
+	}
+}
+
+func TestWakeBeforeParked(t *testing.T) {
+	startLocalNode(t)
+	fake := newFakeClock()
+	defer clock.Set(clock.Real)
+	factory := NewFactory(func() Behavior { return &sleepBehavior{} })
+	factory.IdleTimeout = time.Second
+	actorId := GenMetaId()
+	if _, err := AddMeta(factory.Category, actorId, DefaultDispatch()); err != nil {
+		t.Fatal(err)
+	}
+	server, err := StartActor(actorId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(2 * time.Second)
+	if _, err = server.Call(&activeCheckParams{}); err != nil {
+		t.Fatal(err)
+	}
+	woken, err := server.Call(&wrapParams{Handler: func(ctx interface{}) interface{} {
+		return ctx.(*sleepBehavior).woken
+	}})
+	if err != nil || woken != 1 {
+		t.Fatal("not woken by message: ", woken, err)
+	}
+	_ = gen_server.Cast(actorMgrId, &shutdownSleepParams{})
+	if _, err = GetActorAmount(); err != nil {
+		t.Fatal(err)
+	}
+	if !gen_server.Exists(actorId) {
+		t.Fatal("woken actor parked")
+	}
+}
+
+func TestRpcCallTimeoutByClock(t *testing.T) {
+	startLocalNode(t)
+	AddLocalAgent()
+	fake := newFakeClock()
+	defer clock.Set(clock.Real)
+	var err error
+	if server, err = gen_server.Start(serverName, new(RpcMgr)); err != nil {
+		t.Fatal(err)
+	}
+	defer gen_server.Stop(serverName, "test")
+	pbmsg.Register(func() proto.Message { return &wrappers.StringValue{} })
+	factory, _ := newProbeFactory()
+	block := make(chan struct{})
+	defer close(block)
+	factory.Register(&wrappers.StringValue{}, func(req *api.Request) proto.Message {
+		<-block
+		return nil
+	})
+	actorId := addActor(t, factory, true)
+	done := make(chan error, 1)
+	go func() {
+		_, err := RpcCall(actorId, &wrappers.StringValue{}, &gen_server.Option{Timeout: time.Second})
+		done <- err
+	}()
+	deadline := time.Now().Add(time.Second)
+	for RpcInflight() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("request not added")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err = gen_server.Call(serverName, &CheckTimeoutParams{}); err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(time.Second)
+	select {
+	case err = <-done:
+		if err != ErrTimeout {
+			t.Fatal("unexpected result: ", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("not timed out by clock")
+	}
+}
+
+func TestSleepGraceByClock(t *testing.T) {
+	startLocalNode(t)
+	fake := newFakeClock()
+	defer clock.Set(clock.Real)
+	factory, _ := newProbeFactory()
+	factory.SleepGrace = 90 * time.Second
+	actorId := addActor(t, factory, true)
+	shutdownSleeps := func() {
+		_ = gen_server.Cast(actorMgrId, &shutdownSleepParams{})
+		if _, err := GetActorAmount(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	MarkActorSleep(actorId)
+	shutdownSleeps()
+	fake.Advance(factory.SleepGrace)
+	shutdownSleeps()
+	if !isActorAlive(actorId) {
+		t.Fatal("stopped before sleep grace")
+	}
+	fake.Advance(time.Millisecond)
+	shutdownSleeps()
+	if isActorAlive(actorId) {
+		t.Fatal("not stopped after sleep grace")
+	}
+}
@@ -0,0 +1,86 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package actor
+
+import (
+	"context"
+	"github.com/mafei198/goslib/gen_server"
+	"time"
+)
+
+// call carrying caller's context, dropped if caller gave up before handled
+type contextCallParams struct {
+	ctx context.Context
+	msg interface{}
+}
+
+// CallContext honours deadline and cancellation of ctx, and returns ctx.Err() when
+// ctx is done before the actor responses.
+func CallContext(ctx context.Context, actorId string, msg interface{}) (interface{}, error) {
+	option, err := contextOption(ctx)
+	if err != nil {
+		return nil, err
+	}
+	type result struct {
+		rsp interface{}
+		err error
+	}
+	done := make(chan *result, 1)
+	go func() {
+		rsp, err := Call(actorId, &contextCallParams{ctx: ctx, msg: msg}, option)
+		done <- &result{rsp: rsp, err: err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return r.rsp, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (f *Factory) StartServiceContext(ctx context.Context, id string) error {
+	option, err := contextOption(ctx)
+	if err != nil {
+		return err
+	}
+	return f.StartService(id, option)
+}
+
+// gen_server timeout from ctx deadline, default timeout if no deadline
+func contextOption(ctx context.Context) (*gen_server.Option, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	timeout := gen_server.GetTimeout()
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+		if timeout <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+	}
+	return &gen_server.Option{Timeout: timeout}, nil
+}
@@ -0,0 +1,166 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package actor
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule of cron timers: "minute hour day-of-month month day-of-week"
+// with *, */n, a-b, a-b/n and lists, or "@every <duration>".
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+	every                         time.Duration
+}
+
+var errCronSpec = errors.New("invalid cron spec")
+
+func ParseCron(spec string) (*CronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		every, err := time.ParseDuration(strings.TrimSpace(spec[len("@every "):]))
+		if err != nil || every <= 0 {
+			return nil, errCronSpec
+		}
+		return &CronSchedule{every: every}, nil
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errCronSpec
+	}
+	s := &CronSchedule{}
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 6); err != nil {
+		return nil, err
+	}
+	s.domAny = fields[2] == "*"
+	s.dowAny = fields[4] == "*"
+	return s, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			n, err := strconv.Atoi(part[idx+1:])
+			if err != nil || n <= 0 {
+				return 0, errCronSpec
+			}
+			step = n
+			part = part[:idx]
+		}
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, errCronSpec
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, errCronSpec
+				}
+			} else if step > 1 {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, errCronSpec
+		}
+		for i := from; i <= to; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+// Next time after t in location of t, zero if none within 5 years. Fields
+// are stepped on wall clock, a time skipped by DST moves to the next valid one.
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+	loc := t.Location()
+	next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	if !next.After(t) {
+		// repeated wall clock when DST ends
+		next = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	}
+	t = next
+	limit := t.Year() + 5
+	step := func(next time.Time) {
+		if !next.After(t) {
+			next = t.Add(time.Minute)
+		}
+		t = next
+	}
+	for t.Year() <= limit {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			step(time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if !s.dayMatches(t) {
+			step(time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			step(time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc))
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			step(time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc))
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// day matches either field if both restricted, like cron
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,79 @@
+package actor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2019, 3, 15, 10, 20, 30, 0, time.UTC) // Friday
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2019, 3, 15, 10, 21, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2019, 3, 15, 10, 30, 0, 0, time.UTC)},
+		{"0 4 * * *", time.Date(2019, 3, 16, 4, 0, 0, 0, time.UTC)},
+		{"30 9-11 * * 1-5", time.Date(2019, 3, 15, 10, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 0", time.Date(2019, 3, 17, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", base.Add(90 * time.Second)},
+	}
+	for _, c := range cases {
+		schedule, err := ParseCron(c.spec)
+		if err != nil {
+			t.Fatalf("parse %q: %v", c.spec, err)
+		}
+		if next := schedule.Next(base); !next.Equal(c.next) {
+			t.Errorf("%q next = %v, want %v", c.spec, next, c.next)
+		}
+	}
+}
+
+func TestCronInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "@every -1s"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("%q should be invalid", spec)
+		}
+	}
+}
+
+func TestCronNextInLocation(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	base := time.Date(2019, 3, 15, 10, 45, 0, 0, ist)
+	for spec, want := range map[string]time.Time{
+		"0 11 * * *":   time.Date(2019, 3, 15, 11, 0, 0, 0, ist),
+		"*/20 * * * *": time.Date(2019, 3, 15, 11, 0, 0, 0, ist),
+		"30 10 * * *":  time.Date(2019, 3, 16, 10, 30, 0, 0, ist),
+	} {
+		schedule, _ := ParseCron(spec)
+		if next := schedule.Next(base); !next.Equal(want) {
+			t.Errorf("%q next = %v, want %v", spec, next, want)
+		}
+	}
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no tzdata: ", err)
+	}
+	cases := []struct {
+		spec string
+		from time.Time
+		next time.Time
+	}{
+		// 02:00-03:00 skipped on 2019-03-10
+		{"0 * * * *", time.Date(2019, 3, 10, 1, 30, 0, 0, ny), time.Date(2019, 3, 10, 3, 0, 0, 0, ny)},
+		{"30 2 * * *", time.Date(2019, 3, 10, 1, 0, 0, 0, ny), time.Date(2019, 3, 11, 2, 30, 0, 0, ny)},
+		// 01:00-02:00 repeated on 2019-11-03, fired once
+		{"30 1 * * *", time.Date(2019, 11, 3, 4, 45, 0, 0, time.UTC).In(ny), time.Date(2019, 11, 3, 5, 30, 0, 0, time.UTC)},
+		{"30 1 * * *", time.Date(2019, 11, 3, 5, 40, 0, 0, time.UTC).In(ny), time.Date(2019, 11, 4, 1, 30, 0, 0, ny)},
+		{"0 3 * * *", time.Date(2019, 11, 3, 6, 10, 0, 0, time.UTC).In(ny), time.Date(2019, 11, 3, 3, 0, 0, 0, ny)},
+	}
+	for _, c := range cases {
+		schedule, _ := ParseCron(c.spec)
+		if next := schedule.Next(c.from); !next.Equal(c.next) {
+			t.Errorf("%q from %v next = %v, want %v", c.spec, c.from, next, c.next)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package actor
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/mafei198/gactor/journal"
+	"github.com/mafei198/goslib/misc"
+	"github.com/mafei198/goslib/pbmsg"
+)
+
+// Apply event to behavior, appliers must be deterministic, because
+// state is rebuilt by replaying events. They can't fail, an event is already
+// journaled when applied, so handlers validate before Emit.
+type EventApplier func(ctx interface{}, event proto.Message)
+
+var (
+	errNotEventSourced = errors.New("factory has no journal")
+	errApplierNotFound = errors.New("event applier not found")
+)
+
+// Register applier of event, events are encoded with pbmsg,
+// so event types must be registered to pbmsg too.
+func (f *Factory) RegisterEvent(event proto.Message, applier EventApplier) {
+	f.Appliers[misc.GetType(event)] = applier
+}
+
+// Emit appends events to journal, then applies them to behavior, called
+// by handlers in event sourcing mode, which is enabled by Factory.Journal.
+func (ins *Server) Emit(events ...proto.Message) error {
+	j := ins.Factory.Journal
+	if j == nil {
+		return errNotEventSourced
+	}
+	records := make([]*journal.Event, 0, len(events))
+	for i, event := range events {
+		if _, ok := ins.Factory.Appliers[misc.GetType(event)]; !ok {
+			return errApplierNotFound
+		}
+		data, err := pbmsg.Encode(event)
+		if err != nil {
+			return err
+		}
+		records = append(records, &journal.Event{
+			Seq:  ins.seq + uint64(i) + 1,
+			Data: data,
+		})
+	}
+	if err := j.Append(ins.Meta.Uuid, records...); err != nil {
+		return err
+	}
+	for i, event := range events {
+		ins.seq = records[i].Seq
+		ins.Factory.Appliers[misc.GetType(event)](ins.Actor, event)
+	}
+	return nil
+}
+
+// Sequence of last applied event
+func (ins *Server) EventSeq() uint64 {
+	return ins.seq
+}
+
+// rebuild state by replaying events after the snapshot
+func (ins *Server) replayEvents() error {
+	return ins.Factory.Journal.Replay(ins.Meta.Uuid, ins.seq, func(record *journal.Event) error {
+		event, err := pbmsg.Decode(record.Data)
+		if err != nil {
+			return err
+		}
+		applier, ok := ins.Factory.Appliers[misc.GetType(event)]
+		if !ok {
+			return errApplierNotFound
+		}
+		ins.seq = record.Seq
+		applier(ins.Actor, event.(proto.Message))
+		return nil
+	})
+}
+
+// snapshot of event sourced actor: seq(8) | state
+func encodeSnapshot(seq uint64, state []byte) []byte {
+	data := make([]byte, 8+len(state))
+	binary.BigEndian.PutUint64(data, seq)
+	copy(data[8:], state)
+	return data
+}
+
+func decodeSnapshot(data []byte) (uint64, []byte) {
+	if len(data) < 8 {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint64(data), data[8:]
+}
@@ -28,7 +28,9 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/mafei198/gactor/api"
 	"github.com/mafei198/gactor/cluster"
+	"github.com/mafei198/gactor/journal"
 	rpcproto "github.com/mafei198/gactor/rpc_proto"
+	"github.com/mafei198/gactor/storage"
 	"github.com/mafei198/goslib/gen_server"
 	"github.com/mafei198/goslib/misc"
 	"time"
@@ -39,10 +41,40 @@ type Factory struct {
 	Dispatch    *Dispatch
 	Constructor func() Behavior
 	Handlers    map[string]MsgHandler
+	ErrHandlers map[string]ErrMsgHandler
+	Strategy    string // placement strategy of metas with blank Dispatch.Strategy
+
+	Storage       storage.Storage // storage of Persistent behaviors, default storage if nil
+	FlushInterval time.Duration   // interval of flushing dirty state
+
+	// Event sourcing mode is enabled by Journal, events emitted by Server.Emit
+	// are journaled then applied by Appliers, state is rebuilt from the last
+	// snapshot of Persistent behavior and the events after it.
+	Journal        journal.Journal
+	Appliers       map[string]EventApplier
+	CompactJournal bool // compact events before snapshot, journal is kept as audit trail if false
+
+	// Supervision of panics in handlers, actor is stopped once it restarts
+	// more than MaxRestarts times within RestartWindow.
+	Supervisor    SupervisorStrategy
+	MaxRestarts   int
+	RestartWindow time.Duration
+
+	// Idle policy, ExpireDuration and MaxSleep if zero. Actor inactive for
+	// IdleTimeout is parked to sleep, and stopped after sleeping SleepGrace.
+	// Inactivity is measured by ActiveAt in seconds, IdleTimeout is accurate
+	// to a second.
+	IdleTimeout time.Duration
+	SleepGrace  time.Duration
+	NoPassivate bool // never sleep for inactive
 }
 
 type MsgHandler func(req *api.Request) proto.Message
 
+// Handler able to fail, error is returned to local caller or sent back as
+// api.Error (ErrCodeHandler if it is not an *api.Error) to rpc and agent callers.
+type ErrMsgHandler func(req *api.Request) (proto.Message, error)
+
 var Factories = map[string]*Factory{}
 
 func NewFactory(factory func() Behavior, dispatch ...*Dispatch) *Factory {
@@ -51,6 +83,8 @@ func NewFactory(factory func() Behavior, dispatch ...*Dispatch) *Factory {
 		Category:    category,
 		Constructor: factory,
 		Handlers:    map[string]MsgHandler{},
+		ErrHandlers: map[string]ErrMsgHandler{},
+		Appliers:    map[string]EventApplier{},
 	}
 	if len(dispatch) > 0 {
 		actorAgent.Dispatch = dispatch[0]
@@ -69,11 +103,29 @@ func (f *Factory) Register(msg proto.Message, handler MsgHandler) {
 	f.Handlers[misc.GetType(msg)] = handler
 }
 
+func (f *Factory) RegisterErr(msg proto.Message, handler ErrMsgHandler) {
+	f.ErrHandlers[misc.GetType(msg)] = handler
+}
+
 func (f *Factory) Route(msg interface{}) (MsgHandler, bool) {
 	handler, ok := f.Handlers[misc.GetType(msg)]
 	return handler, ok
 }
 
+// Route to handler of either signature
+func (f *Factory) RouteErr(msg interface{}) (ErrMsgHandler, bool) {
+	name := misc.GetType(msg)
+	if handler, ok := f.ErrHandlers[name]; ok && handler != nil {
+		return handler, true
+	}
+	if handler, ok := f.Handlers[name]; ok && handler != nil {
+		return func(req *api.Request) (proto.Message, error) {
+			return handler(req), nil
+		}, true
+	}
+	return nil, false
+}
+
 func (f *Factory) Create(actorId ...string) (*Meta, error) {
 	var id string
 	if len(actorId) > 0 {
@@ -92,23 +144,26 @@ func (f *Factory) StartService(id string, options ...*gen_server.Option) error {
 	if meta.NodeId == cluster.GetCurrentNodeId() {
 		_, err = StartActor(id, options...)
 		return err
+	}
+	var timeout time.Duration
+	if len(options) > 0 && options[0].Timeout > 0 {
+		timeout = options[0].Timeout
 	} else {
-		client, err := GetStream(meta.Uuid)
-		if err != nil {
-			return err
-		}
-		var timeout time.Duration
-		if len(options) > 0 && options[0].Timeout > 0 {
-			timeout = options[0].Timeout
-		} else {
-			timeout = gen_server.GetTimeout()
-		}
-		timeoutCtx, cancel := context.WithTimeout(context.Background(), timeout)
-		defer cancel()
-		_, err = client.RpcClient.StartActor(timeoutCtx, &rpcproto.StartActorReq{
-			ActorId: meta.Uuid,
-			Timeout: int64(timeout),
-		})
+		timeout = gen_server.GetTimeout()
+	}
+	return startRemoteActor(meta, timeout)
+}
+
+func startRemoteActor(meta *Meta, timeout time.Duration) error {
+	client, err := GetStream(meta.Uuid)
+	if err != nil {
 		return err
 	}
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err = client.RpcClient.StartActor(timeoutCtx, &rpcproto.StartActorReq{
+		ActorId: meta.Uuid,
+		Timeout: int64(timeout),
+	})
+	return err
 }
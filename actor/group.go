@@ -0,0 +1,162 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package actor
+
+import (
+	"context"
+	"github.com/golang/protobuf/proto"
+	"github.com/mafei198/gactor/cluster"
+	"github.com/mafei198/gactor/registry"
+	"github.com/mafei198/goslib/logger"
+	"strings"
+	"sync"
+)
+
+// group name => member actor ids, maintained by etcd agents.GroupAgent
+var CacheGroups = map[string]map[string]bool{}
+
+var groupLock = &sync.RWMutex{}
+
+func GroupPrefix() string {
+	return cluster.GroupPrefix()
+}
+
+func groupKey(group, actorId string) string {
+	return GroupPrefix() + group + "/" + actorId
+}
+
+// Membership is kept until LeaveGroup or the actor dies, it survives
+// passivation, drain and migration.
+func JoinGroup(group, actorId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), registry.RequestTimeout)
+	defer cancel()
+	if err := registry.Current.Put(ctx, groupKey(group, actorId), nil, 0); err != nil {
+		return err
+	}
+	StoreGroupMember(groupKey(group, actorId))
+	return nil
+}
+
+func LeaveGroup(group, actorId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), registry.RequestTimeout)
+	defer cancel()
+	if err := registry.Current.Delete(ctx, groupKey(group, actorId)); err != nil {
+		return err
+	}
+	DelGroupMember(groupKey(group, actorId))
+	return nil
+}
+
+// leave all groups when actor died
+func leaveGroups(actorId string) {
+	var groups []string
+	groupLock.RLock()
+	for group, members := range CacheGroups {
+		if members[actorId] {
+			groups = append(groups, group)
+		}
+	}
+	groupLock.RUnlock()
+	for _, group := range groups {
+		if err := LeaveGroup(group, actorId); err != nil {
+			logger.ERR("leave group failed: ", actorId, group, err)
+		}
+	}
+}
+
+func GroupMembers(group string) []string {
+	groupLock.RLock()
+	defer groupLock.RUnlock()
+	members := make([]string, 0, len(CacheGroups[group]))
+	for actorId := range CacheGroups[group] {
+		members = append(members, actorId)
+	}
+	return members
+}
+
+// Cast msg to members of group, one batched message per node
+func Broadcast(group string, msg proto.Message) error {
+	return CastMulti(GroupMembers(group), msg)
+}
+
+// Load all groups, return revision to watch from
+func LoadGroups() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), registry.RequestTimeout)
+	kvs, revision, err := registry.Current.GetPrefix(ctx, GroupPrefix())
+	cancel()
+	if err != nil {
+		return 0, err
+	}
+	groups := map[string]map[string]bool{}
+	for _, kv := range kvs {
+		addGroupMember(groups, kv.Key)
+	}
+	groupLock.Lock()
+	CacheGroups = groups
+	groupLock.Unlock()
+	return revision, nil
+}
+
+func StoreGroupMember(key string) {
+	groupLock.Lock()
+	defer groupLock.Unlock()
+	addGroupMember(CacheGroups, key)
+}
+
+func DelGroupMember(key string) {
+	group, actorId, ok := parseGroupKey(key)
+	if !ok {
+		return
+	}
+	groupLock.Lock()
+	defer groupLock.Unlock()
+	if members, ok := CacheGroups[group]; ok {
+		delete(members, actorId)
+		if len(members) == 0 {
+			delete(CacheGroups, group)
+		}
+	}
+}
+
+func addGroupMember(groups map[string]map[string]bool, key string) {
+	group, actorId, ok := parseGroupKey(key)
+	if !ok {
+		return
+	}
+	members, ok := groups[group]
+	if !ok {
+		members = map[string]bool{}
+		groups[group] = members
+	}
+	members[actorId] = true
+}
+
+func parseGroupKey(key string) (group, actorId string, ok bool) {
+	key = strings.TrimPrefix(key, GroupPrefix())
+	idx := strings.LastIndex(key, "/")
+	if idx <= 0 {
+		return "", "", false
+	}
+	return key[:idx], key[idx+1:], true
+}
@@ -0,0 +1,53 @@
+package actor
+
+import (
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/mafei198/goslib/gen_server"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGroupBroadcast(t *testing.T) {
+	startLocalNode(t)
+	factory, received := newProbeFactory()
+	group := GenMetaId()
+	memberIds := []string{addActor(t, factory, true), addActor(t, factory, true)}
+	// member not placed on any node
+	idleId := addActor(t, factory, false)
+	ExpireMeta(idleId)
+	for _, actorId := range append(memberIds, idleId) {
+		if err := JoinGroup(group, actorId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Broadcast(group, &wrappers.UInt32Value{Value: 7}); err != nil {
+		t.Fatal(err)
+	}
+	for range memberIds {
+		expectReceived(t, received, "cast:7")
+	}
+	if meta, err := lookupMeta(idleId); err != nil || meta.NodeId != "" || gen_server.Exists(idleId) {
+		t.Fatal("unplaced member started by broadcast: ", meta, err)
+	}
+
+	// dead member leaves group
+	if err := RpcCast(memberIds[0], &wrappers.BytesValue{Value: []byte("boom")}); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{memberIds[1], idleId}
+	sort.Strings(expect)
+	deadline := time.Now().Add(time.Second)
+	for {
+		members := GroupMembers(group)
+		sort.Strings(members)
+		if strings.Join(members, ",") == strings.Join(expect, ",") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("dead member not left: ", members)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,94 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package actor
+
+import (
+	"github.com/mafei198/gactor/clock"
+	"github.com/mafei198/goslib/logger"
+	"sync/atomic"
+	"time"
+)
+
+// Behavior implements Sleeper to flush state before parked and restore after woken up
+type Sleeper interface {
+	OnSleep(server *Server) error
+	OnWake(server *Server) error
+}
+
+type wakeParams struct{}
+
+func (f *Factory) idleTimeout() time.Duration {
+	if f.IdleTimeout > 0 {
+		return f.IdleTimeout
+	}
+	return ExpireDuration * time.Second
+}
+
+func (f *Factory) sleepGrace() time.Duration {
+	if f.SleepGrace > 0 {
+		return f.SleepGrace
+	}
+	return MaxSleep * time.Second
+}
+
+func (f *Factory) idleCheckInterval() time.Duration {
+	if interval := f.idleTimeout() / 2; interval < time.Minute {
+		return interval
+	}
+	return time.Minute
+}
+
+func (ins *Server) checkIdle() {
+	if ins.asleep || ins.Meta.Dispatch.IsDaemon || ins.Factory.NoPassivate {
+		return
+	}
+	idle := time.Duration(clock.Now().Unix()-ins.ActiveAt) * time.Second
+	if idle < ins.Factory.idleTimeout() {
+		return
+	}
+	if sleeper, ok := ins.Actor.(Sleeper); ok {
+		if err := sleeper.OnSleep(ins); err != nil {
+			logger.ERR("actor OnSleep failed: ", ins.Meta.Uuid, err)
+			return
+		}
+	}
+	if err := ins.saveState(false); err != nil {
+		logger.ERR("save state before sleep failed: ", ins.Meta.Uuid, err)
+	}
+	ins.asleep = true
+	markSleep(ins)
+}
+
+func (ins *Server) wake() {
+	if !ins.asleep {
+		return
+	}
+	ins.asleep = false
+	atomic.StoreInt64(&ins.ActiveAt, clock.Now().Unix())
+	if sleeper, ok := ins.Actor.(Sleeper); ok {
+		if err := sleeper.OnWake(ins); err != nil {
+			logger.ERR("actor OnWake failed: ", ins.Meta.Uuid, err)
+		}
+	}
+}
@@ -25,11 +25,15 @@ package actor
 
 import (
 	"errors"
+	"github.com/mafei198/gactor/api"
+	"github.com/mafei198/gactor/clock"
 	"github.com/mafei198/gactor/cluster"
 	"github.com/mafei198/goslib/gen_server"
 	"github.com/mafei198/goslib/logger"
 	"github.com/mafei198/goslib/pool"
 	"runtime"
+	"sort"
+	"sync/atomic"
 	"time"
 )
 
@@ -45,11 +49,14 @@ type Manager struct {
 const (
 	MgrWorking = iota
 	MgrStopping
+	MgrDraining
 )
 
 type SleepActor struct {
-	sleepAt int64
-	server  *gen_server.GenServer
+	sleepAt  time.Time
+	server   *gen_server.GenServer
+	actor    *Server // nil if parked by shutdown
+	activeAt int64   // ActiveAt of actor when sleep requested
 }
 
 const actorMgrId = "ActorId:actor_mgr"
@@ -84,6 +91,85 @@ func (*Manager) Stop() error {
 	return gen_server.Stop(actorMgrId, "shutdown")
 }
 
+type drainParams struct{}
+type categoryActorsParams struct{ category string }
+
+const maxDrainRounds = 10
+
+var errDrainUnfinished = errors.New("drain unfinished, actors remain")
+
+// Stop actors category by category and dispatch them to other nodes, categories
+// are drained in the given order, then the rest by name. Current node should be
+// marked draining before, so actors are never dispatched back. Actors not
+// started are redirected to other nodes while draining, callers retry on the
+// location error.
+func (*Manager) Drain(warmUp bool, categories ...string) error {
+	for round := 0; round < maxDrainRounds; round++ {
+		result, err := gen_server.Call(actorMgrId, &drainParams{})
+		if err != nil {
+			return err
+		}
+		remains := sortCategories(result.([]string), categories)
+		if len(remains) == 0 {
+			return nil
+		}
+		for _, category := range remains {
+			logger.INFO("drain actors: ", category)
+			result, err := gen_server.Call(actorMgrId, &categoryActorsParams{category: category})
+			if err != nil {
+				return err
+			}
+			for actorId, server := range result.(map[string]*gen_server.GenServer) {
+				drainActor(actorId, server, warmUp)
+			}
+		}
+	}
+	return errDrainUnfinished
+}
+
+func sortCategories(remains []string, ordered []string) []string {
+	sort.Strings(remains)
+	exists := map[string]bool{}
+	for _, category := range remains {
+		exists[category] = true
+	}
+	categories := make([]string, 0, len(remains))
+	for _, category := range ordered {
+		if exists[category] {
+			categories = append(categories, category)
+			delete(exists, category)
+		}
+	}
+	for _, category := range remains {
+		if exists[category] {
+			categories = append(categories, category)
+		}
+	}
+	return categories
+}
+
+func drainActor(actorId string, server *gen_server.GenServer, warmUp bool) {
+	if err := server.Stop(stopDrain); err != nil {
+		logger.ERR("drain actor failed: ", actorId, err)
+		return
+	}
+	_ = gen_server.Cast(actorMgrId, &delActorParams{actorId: actorId})
+	meta, err := GetMeta(actorId)
+	if err == nil && meta.NodeId == cluster.GetCurrentNodeId() {
+		// daemon actors keep NodeId after stopped
+		meta, err = dispatchActor(meta)
+	}
+	if err != nil {
+		logger.ERR("dispatch drained actor failed: ", actorId, err)
+		return
+	}
+	if warmUp {
+		if err := startRemoteActor(meta, gen_server.GetTimeout()); err != nil {
+			logger.ERR("warm up drained actor failed: ", actorId, meta.NodeId, err)
+		}
+	}
+}
+
 type startActorParams struct {
 	ActorId string
 }
@@ -117,12 +203,59 @@ func GetActorAmount() (int32, error) {
 	return 0, err
 }
 
-type sleepParams struct{ actorId string }
+type loadParams struct{}
+
+// Collect load of current node
+func GetLoad() (*cluster.Load, error) {
+	result, err := gen_server.Call(actorMgrId, &loadParams{})
+	if err != nil {
+		return nil, err
+	}
+	mem := &runtime.MemStats{}
+	runtime.ReadMemStats(mem)
+	return &cluster.Load{
+		Actors:      result.(map[string]int32),
+		Goroutines:  int32(runtime.NumGoroutine()),
+		HeapAlloc:   mem.HeapAlloc,
+		HeapInuse:   mem.HeapInuse,
+		Mailbox:     MailboxBacklog(),
+		RpcInflight: RpcInflight(),
+		RpcTimeouts: GetRpcStats().Timeouts,
+		UpdatedAt:   clock.Now().Unix(),
+	}, nil
+}
+
+type aliveParams struct{ actorId string }
+
+// Actor is running or sleeping on current node
+func isActorAlive(actorId string) bool {
+	if gen_server.Exists(actorId) {
+		return true
+	}
+	alive, err := gen_server.Call(actorMgrId, &aliveParams{actorId: actorId})
+	return err == nil && alive.(bool)
+}
+
+type sleepParams struct {
+	actorId  string
+	actor    *Server
+	activeAt int64
+}
+type migratedParams struct{ actorId string }
+
+func markActorMigrated(actorId string) {
+	_ = gen_server.Cast(actorMgrId, &migratedParams{actorId: actorId})
+}
 
 func MarkActorSleep(actorId string) {
 	_ = gen_server.Cast(actorMgrId, &sleepParams{actorId: actorId})
 }
 
+// Actor is not parked if it handles a message before the sleep is handled
+func markSleep(ins *Server) {
+	_ = gen_server.Cast(actorMgrId, &sleepParams{actorId: ins.PlayerId, actor: ins, activeAt: ins.ActiveAt})
+}
+
 func (ins *Manager) Init([]interface{}) (err error) {
 	ins.status = MgrWorking
 	ins.workerPool, err = pool.New(runtime.NumCPU(), ins.workerHandler)
@@ -145,7 +278,7 @@ type shutdownActorParams struct {
 func (ins *Manager) workerHandler(msg interface{}) (interface{}, error) {
 	switch params := msg.(type) {
 	case *shutdownActorParams:
-		err := params.server.Stop("shutdown")
+		err := params.server.Stop(stopShutdown)
 		if err != nil {
 			logger.ERR("shutdown: ", params.actorId, " failed: ", err)
 			ins.workerPool.ProcessAsync(&shutdownActorParams{
@@ -159,14 +292,14 @@ func (ins *Manager) workerHandler(msg interface{}) (interface{}, error) {
 	return nil, nil
 }
 
-var locationErr = errors.New("actor not belongs to this server")
+var locationErr error = api.NewError(api.ErrCodeLocation, "actor not belongs to this server")
 var shutDownErr = errors.New("actor manager is shutting down")
 
 func (ins *Manager) HandleCall(req *gen_server.Request) (interface{}, error) {
 	switch params := req.Msg.(type) {
 	case *startActorParams:
 		switch ins.status {
-		case MgrWorking:
+		case MgrWorking, MgrDraining:
 			return ins.handleStartActor(params.ActorId)
 		case MgrStopping:
 			return nil, shutDownErr
@@ -177,6 +310,38 @@ func (ins *Manager) HandleCall(req *gen_server.Request) (interface{}, error) {
 			amount += int32(len(actors))
 		}
 		return amount, nil
+	case *loadParams:
+		actors := map[string]int32{}
+		for category, categorised := range ins.categorisedActors {
+			actors[category] = int32(len(categorised))
+		}
+		return actors, nil
+	case *drainParams:
+		if ins.status == MgrWorking {
+			ins.status = MgrDraining
+		}
+		categories := make([]string, 0)
+		for category, actors := range ins.categorisedActors {
+			if len(actors) > 0 {
+				categories = append(categories, category)
+			}
+		}
+		return categories, nil
+	case *aliveParams:
+		_, sleeping := ins.sleeping[params.actorId]
+		return sleeping || gen_server.Exists(params.actorId), nil
+	case *categoryActorsParams:
+		servers := map[string]*gen_server.GenServer{}
+		for actorId := range ins.categorisedActors[params.category] {
+			if sleep, ok := ins.sleeping[actorId]; ok {
+				servers[actorId] = sleep.server
+			} else if server, ok := gen_server.GetGenServer(actorId); ok {
+				servers[actorId] = server
+			} else {
+				ins.delActor(actorId)
+			}
+		}
+		return servers, nil
 	default:
 		logger.ERR("Actor Manager unhandle call msg: ", req.Msg)
 	}
@@ -186,7 +351,7 @@ func (ins *Manager) HandleCall(req *gen_server.Request) (interface{}, error) {
 func (ins *Manager) HandleCast(req *gen_server.Request) {
 	switch params := req.Msg.(type) {
 	case *sleepParams:
-		ins.handleSleep(params.actorId)
+		ins.handleSleep(params)
 		break
 	case *shutdownSleepParams:
 		ins.scheduleShutdownSleeps()
@@ -198,6 +363,8 @@ func (ins *Manager) HandleCast(req *gen_server.Request) {
 		if !gen_server.Exists(params.actorId) {
 			ins.delActor(params.actorId)
 		}
+	case *migratedParams:
+		ins.handleMigrated(params.actorId)
 	default:
 		logger.ERR("Actor Manager unhandle cast msg: ", req.Msg)
 	}
@@ -216,7 +383,9 @@ func (ins *Manager) handleStartActor(actorId string) (*gen_server.GenServer, err
 
 	// wakeup sleeping actor
 	if sleep, ok := ins.sleeping[actorId]; ok {
+		delete(ins.sleeping, actorId)
 		gen_server.SetGenServer(actorId, sleep.server)
+		_ = castActor(sleep.server, &wakeParams{})
 		return sleep.server, nil
 	}
 
@@ -229,6 +398,15 @@ func (ins *Manager) handleStartActor(actorId string) (*gen_server.GenServer, err
 		logger.ERR("metaGameId: ", meta.NodeId, "nodeId: ", cluster.GetCurrentNodeId())
 		return nil, locationErr
 	}
+	// redirect to other node, otherwise actors started while draining never end
+	if ins.status == MgrDraining {
+		if meta, err = dispatchActor(meta); err != nil {
+			return nil, err
+		}
+		if meta.NodeId != cluster.GetCurrentNodeId() {
+			return nil, locationErr
+		}
+	}
 	actorAgent := GetFactory(meta.Category)
 	server, err := gen_server.Start(actorId, new(Server), meta, actorAgent)
 	ins.addActor(actorId, actorAgent)
@@ -275,10 +453,20 @@ func (ins *Manager) actorExists(actorId string) bool {
 type shutdownSleepParams struct{}
 
 func (ins *Manager) scheduleShutdownSleeps() {
-	now := time.Now().Unix()
+	now := clock.Now()
 	for actorId, sleep := range ins.sleeping {
-		if now-sleep.sleepAt > MaxSleep {
-			err := sleep.server.Stop("shutdown inactive")
+		if sleep.woken() {
+			// woken by a message sent to the parked server directly
+			delete(ins.sleeping, actorId)
+			gen_server.SetGenServer(actorId, sleep.server)
+			continue
+		}
+		grace := MaxSleep * time.Second
+		if factory := ins.getActor(actorId); factory != nil {
+			grace = factory.sleepGrace()
+		}
+		if now.Sub(sleep.sleepAt) > grace {
+			err := sleep.server.Stop(stopInactive)
 			if err != nil {
 				logger.ERR("shutdown inactive actor failed: ", actorId, err)
 			} else {
@@ -286,7 +474,7 @@ func (ins *Manager) scheduleShutdownSleeps() {
 			}
 		}
 	}
-	time.AfterFunc(GcInterval, func() {
+	clock.AfterFunc(GcInterval, func() {
 		_ = gen_server.Cast(actorMgrId, &shutdownSleepParams{})
 	})
 }
@@ -299,7 +487,7 @@ func (ins *Manager) shutdownActors() {
 				ins.shutdownActor(actorId, sleep.server)
 			} else {
 				sleeped++
-				ins.handleSleep(actorId)
+				ins.handleSleep(&sleepParams{actorId: actorId})
 			}
 		}
 	}
@@ -318,19 +506,50 @@ func (ins *Manager) shutdownActor(actorId string, server *gen_server.GenServer)
 	}
 }
 
-func (ins *Manager) handleSleep(actorId string) {
+func (ins *Manager) handleSleep(params *sleepParams) {
+	actorId := params.actorId
 	if ins.getActor(actorId) != nil {
 		server, ok := gen_server.GetGenServer(actorId)
 		if !ok {
 			ins.delActor(actorId)
 			return
 		}
+		sleep := &SleepActor{
+			sleepAt:  clock.Now(),
+			server:   server,
+			actor:    params.actor,
+			activeAt: params.activeAt,
+		}
+		if sleep.woken() {
+			return
+		}
 		gen_server.DelGenServer(actorId)
-		ins.sleeping[actorId] = &SleepActor{
-			sleepAt: time.Now().Unix(),
-			server:  server,
+		ins.sleeping[actorId] = sleep
+	}
+}
+
+// Actor handled a message after sleep was requested
+func (sleep *SleepActor) woken() bool {
+	return sleep.actor != nil && atomic.LoadInt64(&sleep.actor.ActiveAt) != sleep.activeAt
+}
+
+// Forget migrated actor, it's stopped after forwarding queued messages.
+func (ins *Manager) handleMigrated(actorId string) {
+	server, ok := gen_server.GetGenServer(actorId)
+	if !ok {
+		if sleep, sleeping := ins.sleeping[actorId]; sleeping {
+			server, ok = sleep.server, true
 		}
 	}
+	gen_server.DelGenServer(actorId)
+	ins.delActor(actorId)
+	if ok {
+		clock.AfterFunc(MigrateGracePeriod, func() {
+			if err := server.Stop(stopMigrated); err != nil {
+				logger.ERR("stop migrated actor failed: ", actorId, err)
+			}
+		})
+	}
 }
 
 func (ins *Manager) isActorSleep(actorId string) bool {
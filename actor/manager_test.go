@@ -0,0 +1,163 @@
+package actor
+
+import (
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/mafei198/gactor/api"
+	"github.com/mafei198/gactor/cluster"
+	"github.com/mafei198/gactor/registry"
+	rpcproto "github.com/mafei198/gactor/rpc_proto"
+	"github.com/mafei198/goslib/gen_server"
+	"github.com/mafei198/goslib/pbmsg"
+	"strings"
+	"testing"
+	"time"
+)
+
+// single node cluster on memory registry, actors are started in process
+func startLocalNode(t *testing.T) *cluster.Node {
+	if gen_server.Exists(actorMgrId) {
+		node, _ := cluster.FindNode(cluster.GetCurrentNodeId())
+		return node
+	}
+	registry.Set(registry.NewMemoryRegistry())
+	node := cluster.NewNode(cluster.RoleDefault, "127.0.0.1", "0")
+	cluster.SetCurrentNodeId(node.Uuid)
+	cluster.CacheNodes.Store(node.Uuid, node)
+	if err := new(Manager).Start(); err != nil {
+		t.Fatal(err)
+	}
+	return node
+}
+
+// node known to the cluster without serving rpc, removed by the returned func
+func addRemoteNode(role string) (*cluster.Node, func()) {
+	node := cluster.NewNode(role, "127.0.0.1", "0")
+	cluster.CacheNodes.Store(node.Uuid, node)
+	return node, func() { cluster.CacheNodes.Delete(node.Uuid) }
+}
+
+type probeBehavior struct{ count int }
+
+func (b *probeBehavior) OnStart(server *Server) error { return nil }
+func (b *probeBehavior) OnStop(reason string) error   { return nil }
+
+// Probe actors report ActorDown and UInt32Value casts they received to the
+// channel, and panic on BytesValue.
+func newProbeFactory() (*Factory, chan string) {
+	pbmsg.Register(func() proto.Message { return &wrappers.UInt32Value{} })
+	pbmsg.Register(func() proto.Message { return &wrappers.BytesValue{} })
+	received := make(chan string, 100)
+	factory := NewFactory(func() Behavior { return &probeBehavior{} })
+	factory.Supervisor = SuperviseStop
+	factory.Register(&rpcproto.ActorDown{}, func(req *api.Request) proto.Message {
+		down := req.Params.(*rpcproto.ActorDown)
+		received <- "down:" + down.ActorId + ":" + down.Reason
+		return nil
+	})
+	factory.Register(&wrappers.UInt32Value{}, func(req *api.Request) proto.Message {
+		received <- fmt.Sprint("cast:", req.Params.(*wrappers.UInt32Value).Value)
+		return nil
+	})
+	factory.Register(&wrappers.BytesValue{}, func(req *api.Request) proto.Message {
+		panic(string(req.Params.(*wrappers.BytesValue).Value))
+	})
+	return factory, received
+}
+
+func addActor(t *testing.T, factory *Factory, start bool) string {
+	actorId := GenMetaId()
+	if _, err := AddMeta(factory.Category, actorId, DefaultDispatch()); err != nil {
+		t.Fatal(err)
+	}
+	if start {
+		if _, err := StartActor(actorId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return actorId
+}
+
+func expectReceived(t *testing.T, received chan string, prefix string) string {
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case msg := <-received:
+			if strings.HasPrefix(msg, prefix) {
+				return msg
+			}
+		case <-deadline:
+			t.Fatal("not received: ", prefix)
+		}
+	}
+}
+
+// fresh manager after drain, actors started before are not tracked
+func restartManager(t *testing.T) {
+	if err := gen_server.Stop(actorMgrId, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := new(Manager).Start(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	local := startLocalNode(t)
+	factory, _ := newProbeFactory()
+	actorId := addActor(t, factory, true)
+	remote, remove := addRemoteNode(cluster.RoleDefault)
+	defer remove()
+	local.Draining = true
+	defer func() { local.Draining = false }()
+	defer restartManager(t)
+
+	if err := new(Manager).Drain(false); err != nil {
+		t.Fatal(err)
+	}
+	if gen_server.Exists(actorId) {
+		t.Fatal("drained actor running")
+	}
+	if meta, err := lookupMeta(actorId); err != nil || meta.NodeId != remote.Uuid {
+		t.Fatal("drained actor not dispatched to other node: ", meta, err)
+	}
+
+	// placed here before draining, started elsewhere
+	placedId := addActor(t, factory, false)
+	meta, err := lookupMeta(placedId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.NodeId = local.Uuid
+	if _, err := setToEtcd(meta); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := StartActor(placedId); err != locationErr {
+		t.Fatal("actor started by draining node: ", err)
+	}
+	if meta, err := lookupMeta(placedId); err != nil || meta.NodeId != remote.Uuid {
+		t.Fatal("actor not redirected to other node: ", meta, err)
+	}
+}
+
+func TestMailboxBacklogOfWokenActor(t *testing.T) {
+	startLocalNode(t)
+	factory, received := newProbeFactory()
+	actorId := addActor(t, factory, true)
+	MarkActorSleep(actorId)
+	if _, err := GetActorAmount(); err != nil {
+		t.Fatal(err)
+	}
+	// woken by the cast, wake message is counted as well
+	if err := Cast(actorId, &wrappers.UInt32Value{Value: 1}); err != nil {
+		t.Fatal(err)
+	}
+	expectReceived(t, received, "cast:1")
+	if _, err := Wrap(actorId, func(ctx interface{}) interface{} { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if backlog := MailboxBacklog(); backlog != 0 {
+		t.Fatal("unexpected backlog: ", backlog)
+	}
+}
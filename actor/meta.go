@@ -27,51 +27,44 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"github.com/coreos/etcd/mvcc/mvccpb"
+	"github.com/mafei198/gactor/clock"
 	"github.com/mafei198/gactor/cluster"
-	"github.com/mafei198/gactor/etcd"
+	"github.com/mafei198/gactor/registry"
 	"github.com/mafei198/goslib/logger"
 	"github.com/rs/xid"
-	"go.etcd.io/etcd/clientv3"
-	"sync"
-	"time"
 )
 
 type Meta struct {
-	Uuid        string           `json:"uuid"`
-	Category    string           `json:"category"`
-	Dispatch    *Dispatch        `json:"dispatch"`
-	NodeId      string           `json:"node_id"`
-	ActiveAt    int64            `json:"active_at"`
-	CreatedAt   int64            `json:"created_at"`
-	ModRevision int64            `json:"-"`
-	KV          *mvccpb.KeyValue `json:"-"`
+	Uuid        string             `json:"uuid"`
+	Category    string             `json:"category"`
+	Dispatch    *Dispatch          `json:"dispatch"`
+	NodeId      string             `json:"node_id"`
+	ActiveAt    int64              `json:"active_at"`
+	CreatedAt   int64              `json:"created_at"`
+	Timers      []*TimerMeta       `json:"timers,omitempty"` // persistent timers
+	ModRevision int64              `json:"-"`
+	KV          *registry.KeyValue `json:"-"`
 }
 
 const (
 	DispatchTypeDefault = iota // 按负载分配
 	DispatchTypeRole           // 在指定节点类型范围，按负载分配
-	DispatchTypeInMap          // 部署到地图服务所在节点
+	DispatchTypeInMap          // 部署到地图服务所在节点，Value为地图Actor的Id
+	DispatchTypeHash           // 按Actor Id一致性哈希分配，Value为节点类型(可选)
 )
 
 type Dispatch struct {
 	Type     int
 	Value    string
 	IsDaemon bool
+	Strategy string // name of registered cluster.PlacementStrategy, blank for default
 }
 
 const (
 	MetaExpireTime    = 1800 // seconds
-	MetaCacheDuration = 600  // seconds
+	MetaCacheDuration = 600  // seconds, default MetaCacheTTL
 )
 
-type CacheMeta struct {
-	Meta *Meta
-	Kv   *mvccpb.KeyValue
-}
-
-var CacheMetas map[string]*Meta
-
 func DefaultDispatch() *Dispatch {
 	return NewDispatch(DispatchTypeDefault, "")
 }
@@ -85,6 +78,11 @@ func NewDispatch(dispatchType int, value string, isDaemon ...bool) *Dispatch {
 	return dispatch
 }
 
+func (d *Dispatch) WithStrategy(strategy string) *Dispatch {
+	d.Strategy = strategy
+	return d
+}
+
 func FindOrCreate(category, uuid string, dispatch *Dispatch) (*Meta, error) {
 	if meta := getMetaCache(uuid); meta != nil {
 		return meta, nil
@@ -103,7 +101,7 @@ func AddMeta(category, uuid string, dispatch *Dispatch) (*Meta, error) {
 	if meta, err := GetMeta(uuid); err == nil || err != ErrActorMetaNotExists {
 		return meta, err
 	}
-	now := time.Now().Unix()
+	now := clock.Now().Unix()
 	meta := &Meta{
 		Uuid:      uuid,
 		Category:  category,
@@ -127,14 +125,8 @@ func AddMeta(category, uuid string, dispatch *Dispatch) (*Meta, error) {
 var ErrActorMetaNotExists = errors.New("actor meta not exists")
 
 func GetMeta(uuid string) (meta *Meta, err error) {
-	if meta = getMetaCache(uuid); meta == nil {
-		meta, err = getFromEtcd(uuid)
-		if err != nil {
-			return
-		}
-		if meta == nil {
-			return meta, ErrActorMetaNotExists
-		}
+	if meta, err = lookupMeta(uuid); err != nil {
+		return
 	}
 	if meta.NodeId == "" {
 		return dispatchActor(meta)
@@ -145,6 +137,21 @@ func GetMeta(uuid string) (meta *Meta, err error) {
 	return meta, nil
 }
 
+// Meta from cache or etcd, not dispatched
+func lookupMeta(uuid string) (*Meta, error) {
+	if meta := getMetaCache(uuid); meta != nil {
+		return meta, nil
+	}
+	meta, err := getFromEtcd(uuid)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		return nil, ErrActorMetaNotExists
+	}
+	return meta, nil
+}
+
 func (meta *Meta) GetNode() (*cluster.Node, bool) {
 	return cluster.FindNode(meta.NodeId)
 }
@@ -165,25 +172,24 @@ func ExpireMeta(uuid string) {
 // Add daemon actor
 func AddDaemonMeta(meta *Meta) error {
 	if meta.Dispatch.IsDaemon && meta.NodeId != "" {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), registry.RequestTimeout)
 		defer cancel()
-		key := daemonKey(meta.Uuid)
-		_, err := etcd.Client.Put(ctx, key, meta.Uuid)
-		return err
+		key := cluster.DaemonKey(meta.Uuid)
+		return registry.Current.Put(ctx, key, []byte(meta.Uuid), 0)
 	}
 	return nil
 }
 
 // Get all daemon actors
 func GetDaemonMetaIds() ([]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), registry.RequestTimeout)
 	defer cancel()
-	rsp, err := etcd.Client.Get(ctx, daemonPrefix(), clientv3.WithPrefix())
+	kvs, _, err := registry.Current.GetPrefix(ctx, cluster.DaemonPrefix())
 	if err != nil {
 		return nil, err
 	}
 	metaIds := make([]string, 0)
-	for _, kv := range rsp.Kvs {
+	for _, kv := range kvs {
 		metaId := string(kv.Value)
 		metaIds = append(metaIds, metaId)
 	}
@@ -193,7 +199,11 @@ func GetDaemonMetaIds() ([]string, error) {
 var errNodeNotFound = errors.New("dispatch failed, node not found")
 
 func dispatchActor(meta *Meta) (*Meta, error) {
-	game, err := chooseNodeForMeta(meta)
+	return dispatchActorDepth(meta, 0)
+}
+
+func dispatchActorDepth(meta *Meta, depth int) (*Meta, error) {
+	game, err := chooseNodeForMeta(meta, depth)
 	if err != nil {
 		return nil, err
 	}
@@ -208,22 +218,84 @@ func dispatchActor(meta *Meta) (*Meta, error) {
 	return meta, AddDaemonMeta(meta)
 }
 
-func chooseNodeForMeta(meta *Meta) (*cluster.Node, error) {
+// Dispatch.Strategy of meta, Factory.Strategy if blank
+func (meta *Meta) strategy() string {
+	if meta.Dispatch.Strategy != "" {
+		return meta.Dispatch.Strategy
+	}
+	if factory := GetFactory(meta.Category); factory != nil {
+		return factory.Strategy
+	}
+	return ""
+}
+
+func chooseNodeForMeta(meta *Meta, depth int) (*cluster.Node, error) {
 	dispatch := meta.Dispatch
 	var game *cluster.Node
 	var err error
 	switch dispatch.Type {
 	case DispatchTypeDefault:
-		game = cluster.ChooseNode(cluster.RoleDefault)
+		game = cluster.ChooseNodeBy(meta.strategy(), cluster.RoleDefault, meta.Uuid)
 	case DispatchTypeRole:
-		game = cluster.ChooseNode(dispatch.Value)
+		game = cluster.ChooseNodeBy(meta.strategy(), dispatch.Value, meta.Uuid)
 		if game == nil {
-			game = cluster.ChooseNode(cluster.RoleDefault)
+			game = cluster.ChooseNodeBy(meta.strategy(), cluster.RoleDefault, meta.Uuid)
+		}
+	case DispatchTypeInMap:
+		game, err = chooseNodeInMap(meta, depth)
+	case DispatchTypeHash:
+		role := dispatch.Value
+		if role == "" {
+			role = cluster.RoleDefault
 		}
+		game = cluster.ChooseNodeBy(cluster.StrategyConsistentHash, role, meta.Uuid)
 	}
 	return game, err
 }
 
+// max nested dispatches when map actors are InMap dispatched too
+const maxInMapDepth = 3
+
+// Dispatch.Value is the id of the map actor, the actor is placed on the node
+// hosting the map actor's Meta:
+//  1. map actor placed on an alive node: use that node
+//  2. map actor exists but not placed: dispatch map actor first, then follow it
+//  3. map actor not exists, or nested too deep: fallback to DispatchTypeDefault
+func chooseNodeInMap(meta *Meta, depth int) (*cluster.Node, error) {
+	mapId := meta.Dispatch.Value
+	fallback := func() *cluster.Node {
+		return cluster.ChooseNodeBy(meta.strategy(), cluster.RoleDefault, meta.Uuid)
+	}
+	if mapId == "" || mapId == meta.Uuid || depth >= maxInMapDepth {
+		return fallback(), nil
+	}
+	mapMeta := getMetaCache(mapId)
+	if mapMeta == nil {
+		var err error
+		if mapMeta, err = getFromEtcd(mapId); err != nil {
+			return nil, err
+		}
+		if mapMeta == nil {
+			logger.ERR("dispatch in map, map actor not exists: ", mapId, meta.Uuid)
+			return fallback(), nil
+		}
+	}
+	if node, ok := mapMeta.GetNode(); ok {
+		if node.Draining {
+			return fallback(), nil
+		}
+		return node, nil
+	}
+	mapMeta, err := dispatchActorDepth(mapMeta, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	if node, ok := mapMeta.GetNode(); ok {
+		return node, nil
+	}
+	return fallback(), nil
+}
+
 func MetaId(uuid string) string {
 	return "Actor:" + uuid
 }
@@ -233,23 +305,22 @@ func GenMetaId() string {
 }
 
 func getFromEtcd(uuid string) (*Meta, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	rsp, err := etcd.Client.Get(ctx, uuid)
+	ctx, cancel := context.WithTimeout(context.Background(), registry.RequestTimeout)
+	kv, err := registry.Current.Get(ctx, cluster.MetaKey(uuid))
 	cancel()
 	if err != nil {
 		return nil, err
 	}
-	if rsp.Kvs == nil {
+	if kv == nil {
 		return nil, nil
 	}
-	kv := rsp.Kvs[0]
 	meta := &Meta{}
 	if err := json.Unmarshal(kv.Value, meta); err != nil {
 		return nil, err
 	}
 	meta.KV = kv
 	meta.ModRevision = kv.ModRevision
-	return setMetaCache(CacheMetas, meta), nil
+	return setMetaCache(meta), nil
 }
 
 func setToEtcd(meta *Meta) (*Meta, error) {
@@ -257,83 +328,20 @@ func setToEtcd(meta *Meta) (*Meta, error) {
 	if err != nil {
 		return meta, err
 	}
-	// start transaction
-	kv := clientv3.NewKV(etcd.Client)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), registry.RequestTimeout)
 	defer cancel()
-	txn := kv.Txn(ctx)
-	cmp := clientv3.Compare(clientv3.ModRevision(meta.Uuid), "=", meta.ModRevision)
-	putCmd := clientv3.OpPut(meta.Uuid, string(data))
-	getCmd := clientv3.OpGet(meta.Uuid)
-	txn.If(cmp).Then(putCmd).Else(getCmd)
-	// commit
-	txnRsp, err := txn.Commit()
+	kv, ok, err := registry.Current.CompareAndSwap(ctx, cluster.MetaKey(meta.Uuid), meta.ModRevision, data)
 	if err != nil {
 		return meta, err
 	}
-	// process
-	if txnRsp.Succeeded {
-		meta.ModRevision = txnRsp.Header.Revision
-		return setMetaCache(CacheMetas, meta), nil
-	} else {
-		kv := txnRsp.Responses[0].GetResponseRange().Kvs[0]
-		return StoreMeta(CacheMetas, kv), nil
-	}
-}
-
-func StoreMeta(metas map[string]*Meta, kv *mvccpb.KeyValue) *Meta {
-	meta := &Meta{}
-	err := json.Unmarshal(kv.Value, meta)
-	if err == nil {
+	if ok {
 		meta.KV = kv
 		meta.ModRevision = kv.ModRevision
-		return setMetaCache(metas, meta)
-	} else {
-		logger.ERR("unmarshal meta failed: ", err, string(kv.Value))
+		return setMetaCache(meta), nil
 	}
-	return nil
-}
-
-var rwlock = &sync.RWMutex{}
-
-func getMetaCache(uuid string) *Meta {
-	rwlock.RLock()
-	defer rwlock.RUnlock()
-	if cache, ok := CacheMetas[uuid]; ok {
-		return cache
-	}
-	return nil
-}
-
-func setMetaCache(metas map[string]*Meta, meta *Meta) *Meta {
-	rwlock.Lock()
-	defer rwlock.Unlock()
-	if old, ok := metas[meta.Uuid]; ok {
-		if old.ModRevision < meta.ModRevision {
-			metas[meta.Uuid] = meta
-		} else {
-			meta = old
-		}
-	} else {
-		metas[meta.Uuid] = meta
-	}
-	return meta
-}
-
-func DelMetaCache(uuid string, modRevision int64) {
-	rwlock.Lock()
-	defer rwlock.Unlock()
-	if old, ok := CacheMetas[uuid]; ok {
-		if old.ModRevision <= modRevision {
-			delete(CacheMetas, uuid)
-		}
+	if kv == nil {
+		// deleted by others
+		return meta, ErrActorMetaNotExists
 	}
-}
-
-func daemonKey(nodeId string) string {
-	return daemonPrefix() + nodeId
-}
-
-func daemonPrefix() string {
-	return "DadmonMetas:"
+	return StoreMeta(kv), nil
 }
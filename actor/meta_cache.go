@@ -0,0 +1,215 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package actor
+
+import (
+	"container/list"
+	"encoding/json"
+	"github.com/mafei198/gactor/clock"
+	"github.com/mafei198/gactor/registry"
+	"github.com/mafei198/goslib/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metas are cached on demand and kept up to date by the meta watch of
+// agents.ActorAgent, older revisions never replace newer ones. Entries are
+// dropped after MetaCacheTTL, the least recently used beyond MetaCacheSize.
+var (
+	MetaCacheTTL  = MetaCacheDuration * time.Second
+	MetaCacheSize = 100000
+)
+
+type MetaCacheStats struct {
+	Hits    int64
+	Misses  int64
+	Expired int64 // dropped for MetaCacheTTL
+	Evicted int64 // dropped for MetaCacheSize
+	Stale   int64 // writes older than cached or deleted revision
+	Size    int64
+}
+
+var metaCacheStats MetaCacheStats
+
+func GetMetaCacheStats() MetaCacheStats {
+	metaCache.mutex.Lock()
+	size := int64(len(metaCache.entries))
+	metaCache.mutex.Unlock()
+	return MetaCacheStats{
+		Hits:    atomic.LoadInt64(&metaCacheStats.Hits),
+		Misses:  atomic.LoadInt64(&metaCacheStats.Misses),
+		Expired: atomic.LoadInt64(&metaCacheStats.Expired),
+		Evicted: atomic.LoadInt64(&metaCacheStats.Evicted),
+		Stale:   atomic.LoadInt64(&metaCacheStats.Stale),
+		Size:    size,
+	}
+}
+
+type metaEntry struct {
+	meta     *Meta
+	cachedAt time.Time
+	elem     *list.Element
+}
+
+type metaCacheMap struct {
+	mutex   sync.Mutex
+	entries map[string]*metaEntry
+	lru     *list.List       // front is the most recently used
+	deleted map[string]int64 // revision metas deleted at, stops stale writes
+}
+
+var metaCache = newMetaCacheMap()
+
+func newMetaCacheMap() *metaCacheMap {
+	return &metaCacheMap{
+		entries: map[string]*metaEntry{},
+		lru:     list.New(),
+		deleted: map[string]int64{},
+	}
+}
+
+// Cache meta of kv from registry
+func StoreMeta(kv *registry.KeyValue) *Meta {
+	meta := &Meta{}
+	err := json.Unmarshal(kv.Value, meta)
+	if err == nil {
+		meta.KV = kv
+		meta.ModRevision = kv.ModRevision
+		return setMetaCache(meta)
+	} else {
+		logger.ERR("unmarshal meta failed: ", err, string(kv.Value))
+	}
+	return nil
+}
+
+func DelMetaCache(uuid string, modRevision int64) {
+	c := metaCache
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if entry, ok := c.entries[uuid]; ok {
+		if entry.meta.ModRevision > modRevision {
+			return
+		}
+		c.remove(uuid, entry)
+	}
+	if len(c.deleted) >= MetaCacheSize {
+		c.deleted = map[string]int64{}
+	}
+	c.deleted[uuid] = modRevision
+}
+
+// Update meta changed by other nodes if it's cached, watching all metas
+// doesn't fill the cache.
+func UpdateMetaCache(uuid string, kv *registry.KeyValue) {
+	if isMetaCached(uuid) {
+		StoreMeta(kv)
+	}
+}
+
+// Invalidate meta deleted by other nodes if it's cached
+func InvalidateMetaCache(uuid string, modRevision int64) {
+	if isMetaCached(uuid) {
+		DelMetaCache(uuid, modRevision)
+	}
+}
+
+func isMetaCached(uuid string) bool {
+	c := metaCache
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, ok := c.entries[uuid]
+	return ok
+}
+
+// Drop all cached metas, changes may be missed when meta watch is lost
+func ResetMetaCache() {
+	c := metaCache
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = map[string]*metaEntry{}
+	c.lru.Init()
+	c.deleted = map[string]int64{}
+}
+
+func getMetaCache(uuid string) *Meta {
+	c := metaCache
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, ok := c.entries[uuid]
+	if !ok {
+		atomic.AddInt64(&metaCacheStats.Misses, 1)
+		return nil
+	}
+	if clock.Since(entry.cachedAt) > MetaCacheTTL {
+		c.remove(uuid, entry)
+		atomic.AddInt64(&metaCacheStats.Expired, 1)
+		atomic.AddInt64(&metaCacheStats.Misses, 1)
+		return nil
+	}
+	c.lru.MoveToFront(entry.elem)
+	atomic.AddInt64(&metaCacheStats.Hits, 1)
+	return entry.meta
+}
+
+// Return the newest meta of cached and meta
+func setMetaCache(meta *Meta) *Meta {
+	c := metaCache
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if revision, ok := c.deleted[meta.Uuid]; ok {
+		if meta.ModRevision <= revision {
+			atomic.AddInt64(&metaCacheStats.Stale, 1)
+			return meta
+		}
+		delete(c.deleted, meta.Uuid)
+	}
+	if entry, ok := c.entries[meta.Uuid]; ok {
+		if entry.meta.ModRevision >= meta.ModRevision {
+			if entry.meta.ModRevision > meta.ModRevision {
+				atomic.AddInt64(&metaCacheStats.Stale, 1)
+			}
+			return entry.meta
+		}
+		entry.meta = meta
+		entry.cachedAt = clock.Now()
+		c.lru.MoveToFront(entry.elem)
+		return meta
+	}
+	entry := &metaEntry{meta: meta, cachedAt: clock.Now()}
+	entry.elem = c.lru.PushFront(meta.Uuid)
+	c.entries[meta.Uuid] = entry
+	for len(c.entries) > MetaCacheSize {
+		back := c.lru.Back()
+		uuid := back.Value.(string)
+		c.remove(uuid, c.entries[uuid])
+		atomic.AddInt64(&metaCacheStats.Evicted, 1)
+	}
+	return meta
+}
+
+func (c *metaCacheMap) remove(uuid string, entry *metaEntry) {
+	c.lru.Remove(entry.elem)
+	delete(c.entries, uuid)
+}
@@ -0,0 +1,84 @@
+package actor
+
+import (
+	"encoding/json"
+	"github.com/mafei198/gactor/clock"
+	"github.com/mafei198/gactor/registry"
+	"testing"
+	"time"
+)
+
+func cacheMeta(uuid string, revision int64, nodeId string) *Meta {
+	return setMetaCache(&Meta{Uuid: uuid, ModRevision: revision, NodeId: nodeId})
+}
+
+func TestMetaCacheRevisionOrder(t *testing.T) {
+	ResetMetaCache()
+	cacheMeta("order", 5, "n1")
+	if meta := cacheMeta("order", 3, "stale"); meta.NodeId != "n1" {
+		t.Fatal("older revision replaced cached meta")
+	}
+	cacheMeta("order", 7, "n2")
+	if meta := getMetaCache("order"); meta == nil || meta.NodeId != "n2" {
+		t.Fatal("newer revision not cached: ", meta)
+	}
+	DelMetaCache("order", 8)
+	cacheMeta("order", 7, "n2")
+	if getMetaCache("order") != nil {
+		t.Fatal("deleted meta cached again by stale write")
+	}
+	cacheMeta("order", 9, "n3")
+	if meta := getMetaCache("order"); meta == nil || meta.NodeId != "n3" {
+		t.Fatal("recreated meta not cached")
+	}
+}
+
+func TestMetaCacheBounds(t *testing.T) {
+	ResetMetaCache()
+	fake := clock.NewFake(time.Unix(1500000000, 0))
+	clock.Set(fake)
+	defer clock.Set(clock.Real)
+	size := MetaCacheSize
+	MetaCacheSize = 2
+	defer func() { MetaCacheSize = size }()
+
+	before := GetMetaCacheStats()
+	cacheMeta("a", 1, "n")
+	cacheMeta("b", 1, "n")
+	getMetaCache("a")
+	cacheMeta("c", 1, "n")
+	if getMetaCache("b") != nil {
+		t.Fatal("least recently used not evicted")
+	}
+	fake.Advance(MetaCacheTTL + time.Second)
+	if getMetaCache("a") != nil {
+		t.Fatal("expired meta returned")
+	}
+	stats := GetMetaCacheStats()
+	if stats.Evicted-before.Evicted != 1 || stats.Expired-before.Expired != 1 ||
+		stats.Hits-before.Hits != 1 || stats.Misses-before.Misses != 2 || stats.Size != 1 {
+		t.Fatal("unexpected stats: ", stats, before)
+	}
+}
+
+func TestMetaCacheWatchUpdate(t *testing.T) {
+	ResetMetaCache()
+	kv := func(uuid string, revision int64, nodeId string) *registry.KeyValue {
+		data, _ := json.Marshal(&Meta{Uuid: uuid, NodeId: nodeId})
+		return &registry.KeyValue{Key: uuid, Value: data, ModRevision: revision}
+	}
+	UpdateMetaCache("uncached", kv("uncached", 1, "n1"))
+	InvalidateMetaCache("gone", 1)
+	if stats := GetMetaCacheStats(); stats.Size != 0 {
+		t.Fatal("watch filled cache: ", stats.Size)
+	}
+	cacheMeta("cached", 1, "n1")
+	UpdateMetaCache("cached", kv("cached", 2, "n2"))
+	if meta := getMetaCache("cached"); meta == nil || meta.NodeId != "n2" {
+		t.Fatal("cached meta not updated: ", meta)
+	}
+	InvalidateMetaCache("cached", 3)
+	if getMetaCache("cached") != nil {
+		t.Fatal("cached meta not invalidated")
+	}
+}
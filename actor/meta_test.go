@@ -0,0 +1,47 @@
+package actor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInMap(t *testing.T) {
+	startLocalNode(t)
+	factory, _ := newProbeFactory()
+	node, remove := addRemoteNode("inmap")
+	defer remove()
+	add := func(actorId string, dispatch *Dispatch) {
+		if _, err := AddMeta(factory.Category, actorId, dispatch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mapId, childId, grandChildId := GenMetaId(), GenMetaId(), GenMetaId()
+	add(mapId, NewDispatch(DispatchTypeRole, node.Role))
+	add(childId, NewDispatch(DispatchTypeInMap, mapId))
+	add(grandChildId, NewDispatch(DispatchTypeInMap, childId))
+	for _, actorId := range []string{childId, grandChildId} {
+		if meta, err := GetMeta(actorId); err != nil || meta.NodeId != node.Uuid {
+			t.Fatal("not placed on node of map: ", meta, err)
+		}
+	}
+
+	// unplaced actors in map of each other, nested dispatch stops at depth limit
+	aId, bId := GenMetaId(), GenMetaId()
+	add(aId, NewDispatch(DispatchTypeInMap, bId))
+	add(bId, NewDispatch(DispatchTypeInMap, aId))
+	ExpireMeta(aId)
+	ExpireMeta(bId)
+	done := make(chan error, 1)
+	go func() {
+		_, err := GetMeta(aId)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal("cyclic map actor not dispatched: ", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cyclic map dispatch not finished")
+	}
+}
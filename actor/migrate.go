@@ -0,0 +1,295 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package actor
+
+import (
+	"context"
+	"errors"
+	"github.com/mafei198/gactor/api"
+	"github.com/mafei198/gactor/cluster"
+	rpcproto "github.com/mafei198/gactor/rpc_proto"
+	"github.com/mafei198/gactor/storage"
+	"github.com/mafei198/goslib/gen_server"
+	"github.com/mafei198/goslib/logger"
+	"github.com/mafei198/goslib/misc"
+	"github.com/mafei198/goslib/pbmsg"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"time"
+)
+
+// Behavior implements Migratable to be migrated with its state.
+type Migratable interface {
+	MarshalState() ([]byte, error)
+	UnmarshalState(data []byte) error
+}
+
+// Migrated actor keeps forwarding messages queued in its mailbox to the
+// target node for MigrateGracePeriod, then stops.
+const MigrateGracePeriod = 5 * time.Second
+
+// casts waiting to be forwarded by migrated actor
+const forwardQueueSize = 1024
+
+var (
+	errNotMigratable   = errors.New("actor behavior not migratable")
+	errMigrateConflict = errors.New("actor meta changed while migrating")
+	errMigrateTarget   = errors.New("migrate target node not available")
+	errNotShared       = errors.New("actor storage not shared by nodes")
+	errMigrated        = api.NewError(api.ErrCodeLocation, "actor migrated to other node")
+)
+
+// states received from other nodes, restored on actor Init
+var migratedStates = &sync.Map{}
+
+type migrateParams struct{ targetNodeId string }
+
+// Migrate moves an actor hosted by current node to target node. State is
+// handed off with Migratable, Meta is CAS updated to target node, and
+// messages queued during the handoff are replayed on target node.
+func Migrate(actorId, targetNodeId string) error {
+	if targetNodeId == cluster.GetCurrentNodeId() {
+		return nil
+	}
+	if node, ok := cluster.FindNode(targetNodeId); !ok || node.Draining {
+		return errMigrateTarget
+	}
+	meta, err := GetMeta(actorId)
+	if err != nil {
+		return err
+	}
+	if meta.NodeId != cluster.GetCurrentNodeId() {
+		return locationErr
+	}
+	server, err := GetActor(actorId)
+	if err != nil {
+		return err
+	}
+	// handoff includes a remote call
+	timeout := 2 * gen_server.GetTimeout()
+	_, err = server.Call(&migrateParams{targetNodeId: targetNodeId}, &gen_server.Option{Timeout: timeout})
+	return err
+}
+
+// runs in actor's goroutine, messages sent meanwhile are queued in mailbox
+func (ins *Server) handleMigrate(targetNodeId string) error {
+	state, err := ins.marshalMigrateState()
+	if err != nil {
+		return err
+	}
+	current := cluster.GetCurrentNodeId()
+	meta, err := GetMeta(ins.Meta.Uuid)
+	if err != nil {
+		return err
+	}
+	if meta.NodeId != current {
+		return locationErr
+	}
+	moving := *meta
+	moving.NodeId = targetNodeId
+	moved, err := setToEtcd(&moving)
+	if err != nil {
+		return err
+	}
+	if moved.NodeId != targetNodeId {
+		return errMigrateConflict
+	}
+	if err = migrateRemoteActor(moved, state); err != nil {
+		logger.ERR("migrate actor failed: ", moved.Uuid, targetNodeId, err)
+		if !migrateRejected(err) {
+			// target may have started the actor, Meta keeps pointing to it
+			ins.setMigrated(moved)
+			return err
+		}
+		back := *moved
+		back.NodeId = current
+		if _, err := setToEtcd(&back); err != nil {
+			logger.ERR("rollback migrating actor failed: ", moved.Uuid, err)
+		}
+		return err
+	}
+	ins.setMigrated(moved)
+	return nil
+}
+
+// Target didn't start the actor: it's not reached or it answered with failure.
+// Outcome of a timed out or canceled handoff is unknown.
+func migrateRejected(err error) bool {
+	switch status.Code(err) {
+	case codes.DeadlineExceeded, codes.Canceled:
+		return false
+	}
+	return true
+}
+
+func (ins *Server) setMigrated(moved *Meta) {
+	ins.Meta = moved
+	ins.migratedTo = moved.NodeId
+	// tickers are restarted by target node
+	for _, ticker := range ins.tickers {
+		ticker.stop()
+	}
+	ins.tickers = nil
+	ins.forwards = make(chan func(), forwardQueueSize)
+	go func(forwards chan func()) {
+		for forward := range forwards {
+			forward()
+		}
+	}(ins.forwards)
+	markActorMigrated(moved.Uuid)
+}
+
+// Persistent behaviors without Migratable hand off state through storage,
+// which must be shared by nodes (storage.Shared).
+func (ins *Server) marshalMigrateState() ([]byte, error) {
+	if migratable, ok := ins.Actor.(Migratable); ok {
+		return migratable.MarshalState()
+	}
+	if _, ok := ins.Actor.(Persistent); !ok {
+		return nil, errNotMigratable
+	}
+	store := ins.Factory.getStorage()
+	if store == nil {
+		return nil, errNotMigratable
+	}
+	if shared, ok := store.(storage.Shared); !ok || !shared.Shared() {
+		return nil, errNotShared
+	}
+	return nil, ins.saveState(true)
+}
+
+func migrateRemoteActor(meta *Meta, state []byte) error {
+	client, err := GetStream(meta.Uuid)
+	if err != nil {
+		return err
+	}
+	timeout := gen_server.GetTimeout()
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err = client.RpcClient.MigrateActor(timeoutCtx, &rpcproto.MigrateActorReq{
+		ActorId: meta.Uuid,
+		State:   state,
+		Timeout: int64(timeout),
+	})
+	return err
+}
+
+// start migrated actor on target node
+func startMigratedActor(actorId string, state []byte, timeout time.Duration) error {
+	// meta cache of target node may be stale
+	if _, err := getFromEtcd(actorId); err != nil {
+		return err
+	}
+	migratedStates.Store(actorId, state)
+	_, err := StartActor(actorId, &gen_server.Option{Timeout: timeout})
+	if err != nil {
+		migratedStates.Delete(actorId)
+	}
+	return err
+}
+
+func (ins *Server) restoreMigratedState() error {
+	state, ok := migratedStates.Load(ins.Meta.Uuid)
+	if !ok {
+		return nil
+	}
+	migratedStates.Delete(ins.Meta.Uuid)
+	if migratable, ok := ins.Actor.(Migratable); ok && state.([]byte) != nil {
+		return migratable.UnmarshalState(state.([]byte))
+	}
+	return nil
+}
+
+// Messages queued in mailbox of migrated instance are replayed on target node
+// without blocking the actor: Call replays calls answered with errMigrated from
+// caller's goroutine, and casts are sent in order by the forwarding goroutine.
+// Closures of Wrap can't be moved so caller gets a retryable error instead.
+func (ins *Server) handleMigratedCall(msg interface{}) (interface{}, error) {
+	switch msg.(type) {
+	case *activeCheckParams:
+		return nil, nil
+	case *wrapParams, *migrateParams, *flushParams:
+		return nil, locationErr
+	default:
+		return nil, errMigrated
+	}
+}
+
+func (ins *Server) handleMigratedCast(msg interface{}) {
+	actorId := ins.Meta.Uuid
+	switch params := msg.(type) {
+	case *requestParams:
+		ins.forwards <- func() { forwardRequest(actorId, params.request) }
+	case *asyncWrapParams:
+		logger.ERR("drop async wrap of migrated actor: ", actorId, ins.migratedTo)
+	case *timerParams, *wakeParams:
+		// timers are restored and actor is awake on target node
+	default:
+		ins.forwards <- func() {
+			if err := RpcCast(actorId, msg); err != nil {
+				logger.ERR("forward migrated actor cast failed: ", actorId, misc.GetType(msg), err)
+			}
+		}
+	}
+}
+
+// replay request queued during handoff on target node
+func forwardRequest(actorId string, req *api.Request) {
+	switch req.ReqType {
+	case api.ReqCast:
+		if err := RpcCast(actorId, req.Params); err != nil {
+			logger.ERR("forward migrated actor request failed: ", actorId, err)
+		}
+	case api.ReqCall:
+		go func() {
+			rsp, err := RpcCall(actorId, req.Params)
+			if err != nil {
+				logger.ERR("forward migrated actor request failed: ", actorId, err)
+				if err = req.Agent.SendError(req.ReqId, api.ToError(err, api.ErrCodeUnknown)); err != nil {
+					logger.ERR("response forwarded request failed: ", actorId, err)
+				}
+				return
+			}
+			if err = req.Agent.SendData(req.ReqId, rsp.(*RpcRspParams).Data); err != nil {
+				logger.ERR("response forwarded request failed: ", actorId, err)
+			}
+		}()
+	}
+}
+
+// replay local call answered with errMigrated on target node
+func forwardCall(actorId string, msg interface{}, options ...*gen_server.Option) (interface{}, error) {
+	var rsp interface{}
+	var err error
+	if params, ok := msg.(*contextCallParams); ok {
+		rsp, err = RpcCallContext(params.ctx, actorId, params.msg)
+	} else {
+		rsp, err = RpcCall(actorId, msg, options...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pbmsg.Decode(rsp.(*RpcRspParams).Data)
+}
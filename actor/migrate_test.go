@@ -0,0 +1,67 @@
+package actor
+
+import (
+	"errors"
+	"github.com/mafei198/gactor/cluster"
+	"github.com/mafei198/goslib/gen_server"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestMigrateRejected(t *testing.T) {
+	unknown := []error{
+		status.Error(codes.DeadlineExceeded, "timeout"),
+		status.Error(codes.Canceled, "canceled"),
+	}
+	for _, err := range unknown {
+		if migrateRejected(err) {
+			t.Fatal("unknown outcome taken as rejected: ", err)
+		}
+	}
+	rejected := []error{
+		status.Error(codes.Unavailable, "connection refused"),
+		status.Error(codes.Unknown, "start failed"),
+		errors.New("no stream"),
+	}
+	for _, err := range rejected {
+		if !migrateRejected(err) {
+			t.Fatal("rejection not rolled back: ", err)
+		}
+	}
+}
+
+func TestMigrateRollback(t *testing.T) {
+	local := startLocalNode(t)
+	factory, _ := newProbeFactory()
+	actorId := addActor(t, factory, true)
+	remote, remove := addRemoteNode(cluster.RoleDefault)
+	defer remove()
+
+	// remote node doesn't serve rpc, migration is refused
+	if err := Migrate(actorId, remote.Uuid); err == nil {
+		t.Fatal("migrated to unreachable node")
+	}
+	if meta, err := lookupMeta(actorId); err != nil || meta.NodeId != local.Uuid {
+		t.Fatal("meta not rolled back: ", meta, err)
+	}
+	if !gen_server.Exists(actorId) {
+		t.Fatal("actor stopped by failed migration")
+	}
+	if _, err := Wrap(actorId, func(ctx interface{}) interface{} { return nil }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigratedCallNotBlocking(t *testing.T) {
+	ins := &Server{Meta: &Meta{Uuid: GenMetaId()}, migratedTo: GenMetaId()}
+	if _, err := ins.handleMigratedCall(&activeCheckParams{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ins.handleMigratedCall(&flushParams{}); err != locationErr {
+		t.Fatal("unexpected flush of migrated actor: ", err)
+	}
+	if _, err := ins.handleMigratedCall("call"); err != errMigrated {
+		t.Fatal("call not returned for forwarding: ", err)
+	}
+}
@@ -0,0 +1,187 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package actor
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/mafei198/gactor/api"
+	"github.com/mafei198/gactor/cluster"
+	rpcproto "github.com/mafei198/gactor/rpc_proto"
+	"github.com/mafei198/goslib/logger"
+	"github.com/mafei198/goslib/pbmsg"
+)
+
+// Stop reasons of passivation and handoff, actor is started again by next
+// message so watchers are not notified.
+const (
+	stopShutdown = "shutdown"
+	stopInactive = "shutdown inactive"
+	stopDrain    = "drain"
+	stopMigrated = "migrated"
+)
+
+// Reason of ActorDown received by monitoring a target not running
+const DownNoProc = "noproc"
+
+func isDown(reason string) bool {
+	switch reason {
+	case stopShutdown, stopInactive, stopDrain, stopMigrated:
+		return false
+	}
+	return true
+}
+
+func init() {
+	pbmsg.Register(func() proto.Message { return &rpcproto.ActorDown{} })
+	pbmsg.Register(func() proto.Message { return &rpcproto.MonitorActor{} })
+}
+
+// Watcher receives rpcproto.ActorDown through its Factory routes after target
+// died, immediately with DownNoProc if target is not running. Monitors are
+// dropped silently when target is passivated, drained or migrated.
+func Monitor(watcherId, targetId string) error {
+	return castMonitor(&rpcproto.MonitorActor{WatcherId: watcherId, TargetId: targetId})
+}
+
+func Demonitor(watcherId, targetId string) error {
+	return castMonitor(&rpcproto.MonitorActor{WatcherId: watcherId, TargetId: targetId, Remove: true})
+}
+
+// Linked actors watch each other, an actor without ActorDown route is stopped
+// when its linked actor stopped.
+func Link(actorId, peerId string) error {
+	if err := castMonitor(&rpcproto.MonitorActor{WatcherId: actorId, TargetId: peerId, Link: true}); err != nil {
+		return err
+	}
+	return castMonitor(&rpcproto.MonitorActor{WatcherId: peerId, TargetId: actorId, Link: true})
+}
+
+func Unlink(actorId, peerId string) error {
+	if err := castMonitor(&rpcproto.MonitorActor{WatcherId: actorId, TargetId: peerId, Link: true, Remove: true}); err != nil {
+		return err
+	}
+	return castMonitor(&rpcproto.MonitorActor{WatcherId: peerId, TargetId: actorId, Link: true, Remove: true})
+}
+
+// Send to the node target is placed on, a target not placed is not running
+// and it's not dispatched for monitoring.
+func castMonitor(msg *rpcproto.MonitorActor) error {
+	meta, err := placedMeta(msg.TargetId)
+	if err != nil {
+		return err
+	}
+	if meta != nil {
+		request, err := newRpcRequest(api.ReqCast, "", msg.TargetId, msg)
+		if err != nil {
+			return err
+		}
+		request.ToNodeId = meta.NodeId
+		return sendTo(request, meta.NodeId == cluster.GetCurrentNodeId())
+	}
+	if msg.Remove {
+		return nil
+	}
+	return RpcCast(msg.WatcherId, &rpcproto.ActorDown{ActorId: msg.TargetId, Reason: DownNoProc})
+}
+
+func (ins *Server) handleMonitor(msg *rpcproto.MonitorActor) {
+	if msg.Remove {
+		delete(ins.monitors, msg.WatcherId)
+		return
+	}
+	if ins.monitors == nil {
+		ins.monitors = map[string]bool{}
+	}
+	ins.monitors[msg.WatcherId] = ins.monitors[msg.WatcherId] || msg.Link
+}
+
+// Return true if handled as link exit
+func (ins *Server) handleActorDown(msg *rpcproto.ActorDown) bool {
+	linked := ins.monitors[msg.ActorId]
+	if linked {
+		// peer is gone, nothing to notify
+		delete(ins.monitors, msg.ActorId)
+		if _, ok := ins.Factory.RouteErr(msg); !ok {
+			ins.stopAsync("linked actor down: " + msg.ActorId + " " + msg.Reason)
+			return true
+		}
+	}
+	return false
+}
+
+// Monitor messages are delivered to running or sleeping actors only, instead
+// of starting them. A target not running answers MonitorActor with ActorDown,
+// and ActorDown to a watcher not running is dropped. Return true if handled.
+func deliverMonitorMsg(actorId string, msg interface{}) bool {
+	switch params := msg.(type) {
+	case *rpcproto.MonitorActor:
+		if isActorAlive(actorId) {
+			return false
+		}
+		if !params.Remove {
+			down := &rpcproto.ActorDown{ActorId: params.TargetId, Reason: DownNoProc}
+			if err := RpcCast(params.WatcherId, down); err != nil {
+				logger.ERR("notify actor down failed: ", actorId, params.WatcherId, err)
+			}
+		}
+		return true
+	case *rpcproto.ActorDown:
+		return !isActorAlive(actorId)
+	}
+	return false
+}
+
+func (ins *Server) notifyDown(reason string) {
+	if len(ins.monitors) == 0 {
+		return
+	}
+	actorId := ins.Meta.Uuid
+	monitors := ins.monitors
+	// Terminate may run inside actor manager, send outside of it
+	go func() {
+		for watcherId := range monitors {
+			err := RpcCast(watcherId, &rpcproto.ActorDown{ActorId: actorId, Reason: reason})
+			if err != nil {
+				logger.ERR("notify actor down failed: ", actorId, watcherId, err)
+			}
+		}
+	}()
+}
+
+// Monitors follow migrated actor, Meta already points to the target node
+func (ins *Server) handoffMonitors() {
+	if len(ins.monitors) == 0 {
+		return
+	}
+	actorId := ins.Meta.Uuid
+	monitors := ins.monitors
+	go func() {
+		for watcherId, link := range monitors {
+			msg := &rpcproto.MonitorActor{WatcherId: watcherId, TargetId: actorId, Link: link}
+			if err := RpcCast(actorId, msg); err != nil {
+				logger.ERR("handoff monitor failed: ", actorId, watcherId, err)
+			}
+		}
+	}()
+}
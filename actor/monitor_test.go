@@ -0,0 +1,95 @@
+package actor
+
+import (
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/mafei198/goslib/gen_server"
+	"testing"
+	"time"
+)
+
+func TestMonitor(t *testing.T) {
+	startLocalNode(t)
+	factory, received := newProbeFactory()
+	watcherId, targetId := addActor(t, factory, true), addActor(t, factory, true)
+	if err := Monitor(watcherId, targetId); err != nil {
+		t.Fatal(err)
+	}
+	if err := RpcCast(targetId, &wrappers.BytesValue{Value: []byte("boom")}); err != nil {
+		t.Fatal(err)
+	}
+	expectReceived(t, received, "down:"+targetId+":panic: boom")
+
+	// not started for monitoring
+	idleId := addActor(t, factory, false)
+	if err := Monitor(watcherId, idleId); err != nil {
+		t.Fatal(err)
+	}
+	expectReceived(t, received, "down:"+idleId+":"+DownNoProc)
+	if gen_server.Exists(idleId) {
+		t.Fatal("target started by monitoring")
+	}
+}
+
+func TestMonitorSleepingAndUnplaced(t *testing.T) {
+	startLocalNode(t)
+	factory, received := newProbeFactory()
+	watcherId, targetId := addActor(t, factory, true), addActor(t, factory, true)
+	unplacedId := GenMetaId()
+	meta := &Meta{Uuid: unplacedId, Category: factory.Category, Dispatch: DefaultDispatch()}
+	if _, err := setToEtcd(meta); err != nil {
+		t.Fatal(err)
+	}
+	if err := Monitor(watcherId, unplacedId); err != nil {
+		t.Fatal(err)
+	}
+	expectReceived(t, received, "down:"+unplacedId+":"+DownNoProc)
+	if meta, err := lookupMeta(unplacedId); err != nil || meta.NodeId != "" {
+		t.Fatal("target dispatched by monitoring: ", meta, err)
+	}
+
+	MarkActorSleep(targetId)
+	if _, err := GetActorAmount(); err != nil {
+		t.Fatal(err)
+	}
+	if !isActorAlive(targetId) || gen_server.Exists(targetId) {
+		t.Fatal("target not sleeping")
+	}
+	if err := Monitor(watcherId, targetId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Wrap(targetId, func(ctx interface{}) interface{} { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := gen_server.Stop(targetId, "boom"); err != nil {
+		t.Fatal(err)
+	}
+	if down := expectReceived(t, received, "down:"); down != "down:"+targetId+":boom" {
+		t.Fatal("unexpected down: ", down)
+	}
+}
+
+type linkBehavior struct{}
+
+func (b *linkBehavior) OnStart(server *Server) error { return nil }
+func (b *linkBehavior) OnStop(reason string) error   { return nil }
+
+func TestLink(t *testing.T) {
+	startLocalNode(t)
+	factory, _ := newProbeFactory()
+	peerId := addActor(t, factory, true)
+	// no ActorDown route, stopped with its peer
+	actorId := addActor(t, NewFactory(func() Behavior { return &linkBehavior{} }), true)
+	if err := Link(actorId, peerId); err != nil {
+		t.Fatal(err)
+	}
+	if err := RpcCast(peerId, &wrappers.BytesValue{Value: []byte("boom")}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for gen_server.Exists(actorId) {
+		if time.Now().After(deadline) {
+			t.Fatal("linked actor not stopped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
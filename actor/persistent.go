@@ -0,0 +1,128 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package actor
+
+import (
+	"github.com/mafei198/gactor/storage"
+	"github.com/mafei198/goslib/logger"
+	"time"
+)
+
+// Behavior implements Persistent to survive restarts and migration, state is
+// loaded before OnStart, flushed periodically when dirty and saved after OnStop.
+type Persistent interface {
+	// data is nil if nothing saved
+	Load(data []byte) error
+	Save() ([]byte, error)
+	// state changed since last Save
+	Dirty() bool
+}
+
+const DefaultFlushInterval = time.Minute
+
+var defaultStorage storage.Storage
+
+// Set storage for factories without their own Storage
+func SetStorage(s storage.Storage) {
+	defaultStorage = s
+}
+
+func (f *Factory) getStorage() storage.Storage {
+	if f.Storage != nil {
+		return f.Storage
+	}
+	return defaultStorage
+}
+
+type flushParams struct{}
+
+func (ins *Server) loadState() error {
+	if err := ins.restoreState(); err != nil {
+		return err
+	}
+	if _, ok := ins.Actor.(Persistent); ok && ins.Factory.getStorage() != nil {
+		interval := ins.Factory.FlushInterval
+		if interval <= 0 {
+			interval = DefaultFlushInterval
+		}
+		ins.StartTicker(interval, &flushParams{})
+	}
+	return nil
+}
+
+// rebuild state of ins.Actor from storage and journal
+func (ins *Server) restoreState() error {
+	ins.seq, ins.snapshotSeq = 0, 0
+	if persistent, ok := ins.Actor.(Persistent); ok {
+		if store := ins.Factory.getStorage(); store != nil {
+			data, err := store.Load(ins.Meta.Uuid)
+			if err != nil {
+				return err
+			}
+			if ins.Factory.Journal != nil {
+				ins.seq, data = decodeSnapshot(data)
+				ins.snapshotSeq = ins.seq
+			}
+			if err = persistent.Load(data); err != nil {
+				return err
+			}
+		}
+	}
+	if ins.Factory.Journal != nil {
+		return ins.replayEvents()
+	}
+	return nil
+}
+
+// save state, only dirty state if not force
+func (ins *Server) saveState(force bool) error {
+	persistent, ok := ins.Actor.(Persistent)
+	if !ok || ins.crashed {
+		return nil
+	}
+	store := ins.Factory.getStorage()
+	dirty := persistent.Dirty() || ins.seq > ins.snapshotSeq
+	if store == nil || (!force && !dirty) {
+		return nil
+	}
+	data, err := persistent.Save()
+	if err != nil {
+		return err
+	}
+	j := ins.Factory.Journal
+	if j != nil {
+		data = encodeSnapshot(ins.seq, data)
+	}
+	if err = store.Save(ins.Meta.Uuid, data); err != nil {
+		logger.ERR("save actor state failed: ", ins.Meta.Uuid, err)
+		return err
+	}
+	ins.snapshotSeq = ins.seq
+	if j != nil && ins.Factory.CompactJournal {
+		if err := j.Compact(ins.Meta.Uuid, ins.seq); err != nil {
+			logger.ERR("compact journal failed: ", ins.Meta.Uuid, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package actor
+
+import (
+	"context"
+	"github.com/golang/protobuf/proto"
+	"github.com/mafei198/gactor/api"
+	"github.com/mafei198/gactor/clock"
+	"github.com/mafei198/gactor/cluster"
+	"github.com/mafei198/gactor/registry"
+	rpcproto "github.com/mafei198/gactor/rpc_proto"
+	"github.com/mafei198/goslib/gen_server"
+	"github.com/mafei198/goslib/logger"
+	"github.com/mafei198/goslib/pbmsg"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	pbmsg.Register(func() proto.Message { return &rpcproto.PublishMsg{} })
+}
+
+// topic => node ids having subscribers, maintained by etcd agents.TopicAgent
+var CacheTopics = map[string]map[string]bool{}
+
+// topic => subscribers on current node
+var localTopics = map[string]map[string]bool{}
+
+// topics of current node registered with nodeLease
+var registeredTopics = map[string]bool{}
+
+var (
+	topicLock = &sync.RWMutex{}
+	localLock = &sync.Mutex{}
+	nodeLease registry.LeaseID
+	// serializes registry I/O of topics, localLock is never held during I/O
+	topicIOLock = &sync.Mutex{}
+)
+
+// Interval of retrying topics failed to register with node lease
+var TopicRetryInterval = time.Second
+
+// Key of node subscribing topic, bound to node lease so it is removed with the node
+func TopicPrefix() string {
+	return cluster.TopicPrefix()
+}
+
+func topicKey(topic, nodeId string) string {
+	return TopicPrefix() + topic + "/" + nodeId
+}
+
+// Called when node lease granted, subscriptions of current node are registered with it
+func SetNodeLease(lease registry.LeaseID) {
+	topicIOLock.Lock()
+	localLock.Lock()
+	nodeLease = lease
+	localLock.Unlock()
+	registeredTopics = map[string]bool{}
+	topicIOLock.Unlock()
+	syncTopics()
+}
+
+// retried until every local topic is registered
+func syncTopics() {
+	localLock.Lock()
+	topics := make([]string, 0, len(localTopics))
+	for topic := range localTopics {
+		topics = append(topics, topic)
+	}
+	localLock.Unlock()
+	failed := false
+	for _, topic := range topics {
+		if err := syncTopic(topic); err != nil {
+			logger.ERR("register topic failed: ", topic, err)
+			failed = true
+		}
+	}
+	if failed {
+		clock.AfterFunc(TopicRetryInterval, syncTopics)
+	}
+}
+
+// register or remove topic of current node to match local subscribers
+func syncTopic(topic string) error {
+	topicIOLock.Lock()
+	defer topicIOLock.Unlock()
+	localLock.Lock()
+	_, subscribed := localTopics[topic]
+	lease := nodeLease
+	localLock.Unlock()
+	if subscribed == registeredTopics[topic] {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), registry.RequestTimeout)
+	defer cancel()
+	key := topicKey(topic, cluster.GetCurrentNodeId())
+	if !subscribed {
+		if err := registry.Current.Delete(ctx, key); err != nil {
+			return err
+		}
+		delete(registeredTopics, topic)
+		return nil
+	}
+	// registered by SetNodeLease if lease not granted yet
+	if lease == 0 {
+		return nil
+	}
+	if err := registry.Current.Put(ctx, key, nil, lease); err != nil {
+		return err
+	}
+	registeredTopics[topic] = true
+	return nil
+}
+
+// Subscription is rolled back if topic can't be registered
+func Subscribe(topic, actorId string) error {
+	localLock.Lock()
+	subscribers, ok := localTopics[topic]
+	if !ok {
+		subscribers = map[string]bool{}
+		localTopics[topic] = subscribers
+	}
+	subscribed := subscribers[actorId]
+	subscribers[actorId] = true
+	localLock.Unlock()
+	err := syncTopic(topic)
+	if err != nil && !subscribed {
+		removeSubscriber(topic, actorId)
+	}
+	return err
+}
+
+func Unsubscribe(topic, actorId string) error {
+	if !removeSubscriber(topic, actorId) {
+		return nil
+	}
+	return syncTopic(topic)
+}
+
+// return true if it was the last subscriber of topic
+func removeSubscriber(topic, actorId string) bool {
+	localLock.Lock()
+	defer localLock.Unlock()
+	subscribers, ok := localTopics[topic]
+	if !ok {
+		return false
+	}
+	delete(subscribers, actorId)
+	if len(subscribers) > 0 {
+		return false
+	}
+	delete(localTopics, topic)
+	return true
+}
+
+// Publish msg to subscribers of all nodes, at most once: failed nodes are not retried.
+// Sleeping subscribers miss it instead of being woken, so they can still be stopped idle.
+func Publish(topic string, msg proto.Message) error {
+	data, err := pbmsg.Encode(msg)
+	if err != nil {
+		return err
+	}
+	deliverPublish(topic, data)
+	publishMsg, err := pbmsg.Encode(&rpcproto.PublishMsg{Topic: topic, Data: data})
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for _, nodeId := range topicNodes(topic) {
+		if nodeId == cluster.GetCurrentNodeId() {
+			continue
+		}
+		if err := sendPublish(nodeId, publishMsg); err != nil {
+			logger.ERR("publish to node failed: ", topic, nodeId, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func sendPublish(nodeId string, data []byte) error {
+	node, ok := cluster.FindNode(nodeId)
+	if !ok {
+		return errNodeNotFound
+	}
+	stream, err := GetStreamClient(node)
+	if err != nil {
+		return err
+	}
+	return stream.StreamClient.Send(&rpcproto.StreamAgentMsg{
+		ReqType:      api.ReqPublish,
+		Data:         data,
+		OriginNodeId: cluster.GetCurrentNodeId(),
+	})
+}
+
+func deliverPublish(topic string, data []byte) {
+	localLock.Lock()
+	subscribers := make([]string, 0, len(localTopics[topic]))
+	for actorId := range localTopics[topic] {
+		subscribers = append(subscribers, actorId)
+	}
+	localLock.Unlock()
+	for _, actorId := range subscribers {
+		server, ok := gen_server.GetGenServer(actorId)
+		if !ok {
+			// sleeping subscriber
+			continue
+		}
+		msg, err := pbmsg.Decode(data)
+		if err != nil {
+			logger.ERR("decode publish failed: ", topic, err)
+			return
+		}
+		request := api.NewRequest(nil, api.ReqCast, 0, msg)
+		if err = castActor(server, &requestParams{request: request}); err != nil {
+			logger.ERR("deliver publish failed: ", topic, actorId, err)
+		}
+	}
+}
+
+func topicNodes(topic string) []string {
+	topicLock.RLock()
+	defer topicLock.RUnlock()
+	nodes := make([]string, 0, len(CacheTopics[topic]))
+	for nodeId := range CacheTopics[topic] {
+		nodes = append(nodes, nodeId)
+	}
+	return nodes
+}
+
+// Load all topics, return revision to watch from
+func LoadTopics() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), registry.RequestTimeout)
+	kvs, revision, err := registry.Current.GetPrefix(ctx, TopicPrefix())
+	cancel()
+	if err != nil {
+		return 0, err
+	}
+	topics := map[string]map[string]bool{}
+	for _, kv := range kvs {
+		addTopicNode(topics, kv.Key)
+	}
+	topicLock.Lock()
+	CacheTopics = topics
+	topicLock.Unlock()
+	return revision, nil
+}
+
+func StoreTopicNode(key string) {
+	topicLock.Lock()
+	defer topicLock.Unlock()
+	addTopicNode(CacheTopics, key)
+}
+
+func DelTopicNode(key string) {
+	topic, nodeId, ok := parseTopicKey(key)
+	if !ok {
+		return
+	}
+	topicLock.Lock()
+	defer topicLock.Unlock()
+	if nodes, ok := CacheTopics[topic]; ok {
+		delete(nodes, nodeId)
+		if len(nodes) == 0 {
+			delete(CacheTopics, topic)
+		}
+	}
+}
+
+func addTopicNode(topics map[string]map[string]bool, key string) {
+	topic, nodeId, ok := parseTopicKey(key)
+	if !ok {
+		return
+	}
+	nodes, ok := topics[topic]
+	if !ok {
+		nodes = map[string]bool{}
+		topics[topic] = nodes
+	}
+	nodes[nodeId] = true
+}
+
+func parseTopicKey(key string) (topic, nodeId string, ok bool) {
+	key = strings.TrimPrefix(key, TopicPrefix())
+	idx := strings.LastIndex(key, "/")
+	if idx <= 0 {
+		return "", "", false
+	}
+	return key[:idx], key[idx+1:], true
+}
+
+// Subscribe topic, unsubscribed automatically when actor terminated
+func (ins *Server) Subscribe(topic string) error {
+	if err := Subscribe(topic, ins.Meta.Uuid); err != nil {
+		return err
+	}
+	if ins.topics == nil {
+		ins.topics = map[string]bool{}
+	}
+	ins.topics[topic] = true
+	return nil
+}
+
+func (ins *Server) Unsubscribe(topic string) error {
+	delete(ins.topics, topic)
+	return Unsubscribe(topic, ins.Meta.Uuid)
+}
+
+func (ins *Server) unsubscribeAll() {
+	for topic := range ins.topics {
+		if err := Unsubscribe(topic, ins.Meta.Uuid); err != nil {
+			logger.ERR("unsubscribe failed: ", ins.Meta.Uuid, topic, err)
+		}
+	}
+	ins.topics = nil
+}
@@ -0,0 +1,49 @@
+package actor
+
+import (
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/mafei198/goslib/gen_server"
+	"testing"
+	"time"
+)
+
+func TestPubSub(t *testing.T) {
+	startLocalNode(t)
+	factory, received := newProbeFactory()
+	topic := GenMetaId()
+	for i := 0; i < 2; i++ {
+		if err := Subscribe(topic, addActor(t, factory, true)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Publish(topic, &wrappers.UInt32Value{Value: 9}); err != nil {
+		t.Fatal(err)
+	}
+	expectReceived(t, received, "cast:9")
+	expectReceived(t, received, "cast:9")
+}
+
+func TestPubSubSleepingSubscriber(t *testing.T) {
+	startLocalNode(t)
+	factory, received := newProbeFactory()
+	topic := GenMetaId()
+	actorId := addActor(t, factory, true)
+	if err := Subscribe(topic, actorId); err != nil {
+		t.Fatal(err)
+	}
+	MarkActorSleep(actorId)
+	if _, err := GetActorAmount(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Publish(topic, &wrappers.UInt32Value{Value: 1}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-received:
+		t.Fatal("sleeping subscriber received: ", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if gen_server.Exists(actorId) {
+		t.Fatal("sleeping subscriber woken")
+	}
+}
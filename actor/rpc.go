@@ -24,12 +24,13 @@ THE SOFTWARE.
 package actor
 
 import (
+	"context"
 	"github.com/mafei198/gactor/api"
+	"github.com/mafei198/gactor/clock"
 	"github.com/mafei198/gactor/cluster"
 	rpcproto "github.com/mafei198/gactor/rpc_proto"
 	"github.com/mafei198/goslib/gen_server"
 	"github.com/mafei198/goslib/pbmsg"
-	"math"
 	"sync/atomic"
 	"time"
 )
@@ -43,18 +44,43 @@ type RpcClient struct {
 type RpcRequest struct {
 	*rpcproto.StreamAgentMsg
 	Params    interface{}
-	CreatedAt int64
+	CreatedAt int64         // unix nano
+	Timeout   time.Duration // default timeout if 0, Deadline takes precedence
 	Handler   RpcHandler
-	Req       *gen_server.Request
+	ToNodeId  string // node hosting target actor when sent
+	// Deprecated: responded if set, use RpcCall or Handler instead.
+	Req      *gen_server.Request
+	done     chan *rpcResult
+	expireAt int64
+	index    int // index in RpcMgr timeout heap
+}
+
+type rpcResult struct {
+	rsp interface{}
+	err error
 }
 
 var rpcRequestId int64
 
+// unix nano the request times out at
+func (r *RpcRequest) ExpireAt() int64 {
+	if r.Deadline > 0 {
+		return r.Deadline
+	}
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = gen_server.GetTimeout()
+	}
+	return r.CreatedAt + int64(timeout)
+}
+
+// Resolve node of target actor, the response must come from it
 func (r *RpcRequest) IsLocal() (bool, error) {
 	meta, err := GetMeta(r.ToActorId)
 	if err != nil {
 		return false, err
 	}
+	r.ToNodeId = meta.NodeId
 	return meta.NodeId == cluster.GetCurrentNodeId(), nil
 }
 
@@ -67,28 +93,78 @@ func RpcCast(toActorId string, params interface{}) error {
 	return sendRequest(request)
 }
 
-// 同步Call
-func RpcCall(toActorId string, params interface{}) (interface{}, error) {
+// 同步Call, timed out by RpcMgr on clock
+func RpcCall(toActorId string, params interface{}, options ...*gen_server.Option) (interface{}, error) {
+	return rpcCall(context.Background(), toActorId, params, callTimeout(options))
+}
+
+// 同步Call, deadline of ctx is sent to remote node so expired request is dropped
+func RpcCallContext(ctx context.Context, toActorId string, params interface{}) (interface{}, error) {
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		if timeout = time.Until(deadline); timeout <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+	}
+	return rpcCall(ctx, toActorId, params, timeout)
+}
+
+// Deadline is clock.Now()+timeout, the time base RpcMgr and remote nodes
+// check it with. Timeout of gen_server is used if timeout is zero.
+func rpcCall(ctx context.Context, toActorId string, params interface{}, timeout time.Duration) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	request, err := newRpcRequest(api.ReqCall, "", toActorId, params)
 	if err != nil {
 		return nil, err
 	}
-	if err := sendRequest(request); err != nil {
+	if timeout > 0 {
+		request.Deadline = clock.Now().Add(timeout).UnixNano()
+	}
+	request.done = make(chan *rpcResult, 1)
+	isLocal, err := request.IsLocal()
+	if err != nil {
 		return nil, err
 	}
-	return WaitForRpcRequest(request)
+	// register before sending, otherwise a fast response may arrive first
+	if err := AddRpcRequest(request); err != nil {
+		return nil, err
+	}
+	if err := sendTo(request, isLocal); err != nil {
+		CancelRpcRequest(request)
+		return nil, err
+	}
+	select {
+	case result := <-request.done:
+		if result.err == ErrTimeout && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return result.rsp, result.err
+	case <-ctx.Done():
+		CancelRpcRequest(request)
+		return nil, ctx.Err()
+	}
 }
 
 // 异步发送RPC消息，并异步回调结果
-func RpcAsyncCall(fromActorId, toActorId string, params interface{}, callback RpcHandler) error {
+func RpcAsyncCall(fromActorId, toActorId string, params interface{}, callback RpcHandler, options ...*gen_server.Option) error {
 	request, err := newRpcRequest(api.ReqCall, fromActorId, toActorId, params)
 	if err != nil {
 		return err
 	}
 	request.Handler = callback
-	err = sendRequest(request)
-	if err == nil {
-		return AddRpcRequest(request)
+	request.Timeout = callTimeout(options)
+	request.Deadline = request.ExpireAt()
+	isLocal, err := request.IsLocal()
+	if err != nil {
+		return err
+	}
+	if err = AddRpcRequest(request); err != nil {
+		return err
+	}
+	if err = sendTo(request, isLocal); err != nil {
+		CancelRpcRequest(request)
 	}
 	return err
 }
@@ -98,11 +174,19 @@ func sendRequest(request *RpcRequest) error {
 	if err != nil {
 		return err
 	}
+	return sendTo(request, isLocal)
+}
+
+func sendTo(request *RpcRequest, isLocal bool) error {
 	if isLocal {
 		agent := GetStreamAgent(cluster.GetCurrentNodeId())
 		return agent.LocalRequest(request)
 	} else {
-		stream, err := GetStream(request.ToActorId)
+		node, ok := cluster.FindNode(request.ToNodeId)
+		if !ok {
+			return errNodeNotFound
+		}
+		stream, err := GetStreamClient(node)
 		if err != nil {
 			return err
 		}
@@ -118,18 +202,27 @@ func sendRequest(request *RpcRequest) error {
 func newRpcRequest(reqType int32, fromActorId, toActorId string, msg interface{}) (*RpcRequest, error) {
 	reqId := genRpcReqId()
 	agentMsg := &rpcproto.StreamAgentMsg{
-		ReqId:       reqId,
-		ReqType:     reqType,
-		FromActorId: fromActorId,
-		ToActorId:   toActorId,
+		ReqId:        reqId,
+		ReqType:      reqType,
+		FromActorId:  fromActorId,
+		ToActorId:    toActorId,
+		OriginNodeId: cluster.GetCurrentNodeId(),
 	}
 	return &RpcRequest{
 		StreamAgentMsg: agentMsg,
 		Params:         msg,
-		CreatedAt:      time.Now().Unix(),
+		CreatedAt:      clock.Now().UnixNano(),
+		index:          -1,
 	}, nil
 }
 
-func genRpcReqId() int32 {
-	return int32(atomic.AddInt64(&rpcRequestId, 1) % math.MaxInt32)
+func genRpcReqId() int64 {
+	return atomic.AddInt64(&rpcRequestId, 1)
+}
+
+func callTimeout(options []*gen_server.Option) time.Duration {
+	if len(options) > 0 && options[0].Timeout > 0 {
+		return options[0].Timeout
+	}
+	return gen_server.GetTimeout()
 }
@@ -23,15 +23,22 @@ THE SOFTWARE.
 */
 package actor
 
-import rpcProto "github.com/mafei198/gactor/rpc_proto"
+import (
+	"github.com/mafei198/gactor/api"
+	rpcProto "github.com/mafei198/gactor/rpc_proto"
+)
 
 type RpcAgent struct {
 	*rpcProto.StreamAgentMsg
 	s *RpcStream
 }
 
-func (r *RpcAgent) SendData(reqId int32, data []byte) error {
-	return r.s.SendData(reqId, r.FromActorId, data)
+func (r *RpcAgent) SendData(reqId int64, data []byte) error {
+	return r.s.SendData(reqId, r.FromActorId, r.OriginNodeId, data)
+}
+
+func (r *RpcAgent) SendError(reqId int64, err *api.Error) error {
+	return r.s.SendError(reqId, r.FromActorId, r.OriginNodeId, err)
 }
 
 func (r *RpcAgent) GetActorId() string {
@@ -24,24 +24,41 @@ THE SOFTWARE.
 package actor
 
 import (
-	"errors"
+	"container/heap"
+	"github.com/mafei198/gactor/api"
+	"github.com/mafei198/gactor/clock"
+	"github.com/mafei198/gactor/cluster"
+	rpcproto "github.com/mafei198/gactor/rpc_proto"
 	"github.com/mafei198/goslib/gen_server"
 	"github.com/mafei198/goslib/logger"
+	"sync/atomic"
 	"time"
 )
 
 type RpcMgr struct {
-	rpcRequests     map[int32]*RpcRequest
-	syncRpcRequests map[int32]*gen_server.Request
-	ticker          *time.Ticker
+	rpcRequests map[rpcKey]*RpcRequest
+	timeouts    rpcTimeoutHeap
+	timer       clock.Timer
+	timerAt     int64 // unix nano the timer fires at
+}
+
+// Pending request is keyed by node it's sent to, so a response with the same
+// ReqId from another node never completes it.
+type rpcKey struct {
+	nodeId string
+	reqId  int64
+}
+
+func (r *RpcRequest) key() rpcKey {
+	return rpcKey{nodeId: r.ToNodeId, reqId: r.ReqId}
 }
 
 const serverName = "__rpc_mgr__"
 
 var server *gen_server.GenServer
 
-func (m *RpcMgr) Start() (port int, err error) {
-	if port, err = StartRpcServer(); err != nil {
+func (m *RpcMgr) Start(host string, minPort, maxPort int) (port int, err error) {
+	if port, err = StartRpcServer(host, minPort, maxPort); err != nil {
 		return
 	}
 	if err = m.StartClient(); err != nil {
@@ -61,11 +78,14 @@ func (m *RpcMgr) Stop() error {
 	return gen_server.Stop(serverName, "shutdown")
 }
 
-func OnRpcRsp(reqId int32, fromActorId string, data []byte) {
+func OnRpcRsp(nodeId string, rsp *rpcproto.StreamAgentRsp) {
 	err := server.Cast(&RpcRspParams{
-		ReqId:     reqId,
-		AccountId: fromActorId,
-		Data:      data,
+		ReqId:        rsp.ReqId,
+		AccountId:    rsp.FromActorId,
+		Data:         rsp.Data,
+		Err:          api.FromCode(rsp.ErrCode, rsp.ErrMsg),
+		NodeId:       nodeId,
+		OriginNodeId: rsp.OriginNodeId,
 	})
 	if err != nil {
 		logger.ERR("OnRpcRsp failed: ", err)
@@ -76,98 +96,109 @@ func AddRpcRequest(request *RpcRequest) error {
 	return server.Cast(&AddRpcParams{rpcRequest: request})
 }
 
+// Deprecated: the request must be sent after it's registered, use RpcCall.
 func WaitForRpcRequest(request *RpcRequest) (interface{}, error) {
-	rsp, err := server.ManualCall(&AddRpcParams{rpcRequest: request})
-	return rsp, err
+	request.done = make(chan *rpcResult, 1)
+	if err := AddRpcRequest(request); err != nil {
+		return nil, err
+	}
+	result := <-request.done
+	return result.rsp, result.err
+}
+
+func CancelRpcRequest(request *RpcRequest) {
+	if err := server.Cast(&cancelRpcParams{key: request.key()}); err != nil {
+		logger.ERR("CancelRpcRequest failed: ", err)
+	}
 }
 
 func (m *RpcMgr) Init([]interface{}) (err error) {
-	m.rpcRequests = map[int32]*RpcRequest{}
-	ticker := time.NewTicker(1 * time.Second)
-	m.ticker = ticker
-	msg := &CheckTimeoutParams{}
-	go func() {
-		var err error
-		for range ticker.C {
-			_, err = gen_server.Call(serverName, msg)
-			if err != nil {
-				logger.ERR("rpc mgr ticker failed: ", err)
-			}
-		}
-	}()
+	m.rpcRequests = map[rpcKey]*RpcRequest{}
 	return nil
 }
 
 type CheckTimeoutParams struct{}
 
 func (m *RpcMgr) HandleCall(req *gen_server.Request) (interface{}, error) {
-	switch params := req.Msg.(type) {
+	switch req.Msg.(type) {
 	case *CheckTimeoutParams:
-		now := time.Now().Unix()
-		for _, req := range m.rpcRequests {
-			if time.Duration(now-req.CreatedAt)*time.Second >= gen_server.GetTimeout() {
-				m.rpcTimeout(req)
-			}
-		}
-		return nil, nil
-	case *AddRpcParams:
-		params.rpcRequest.Req = req
-		m.addRpcRequest(params)
+		m.checkTimeout()
 	}
 	return nil, nil
 }
 
 func (m *RpcMgr) HandleCast(req *gen_server.Request) {
 	switch params := req.Msg.(type) {
+	case *CheckTimeoutParams:
+		m.checkTimeout()
 	case *RpcRspParams:
 		m.rpcRsp(params)
 	case *AddRpcParams:
 		m.addRpcRequest(params)
+	case *cancelRpcParams:
+		if req := m.rpcRequests[params.key]; req != nil {
+			atomic.AddInt64(&rpcStats.Canceled, 1)
+			m.delRpcRequest(req)
+		}
 	}
 }
 
 func (m *RpcMgr) Terminate(reason string) (err error) {
-	if m.ticker != nil {
-		m.ticker.Stop()
+	if m.timer != nil {
+		m.timer.Stop()
 	}
 	logger.INFO("RpcMgr terminate:", reason)
 	return nil
 }
 
 type RpcRspParams struct {
-	ReqId     int32
-	AccountId string
-	Data      []byte
+	ReqId        int64
+	AccountId    string
+	Data         []byte
+	Err          error  // remote error, see api.Error
+	NodeId       string // node sent the response
+	OriginNodeId string // node sent the request
 }
 
 func (m *RpcMgr) rpcRsp(params *RpcRspParams) {
-	if req := m.getRpcRequest(params.ReqId); req != nil {
-		m.delRpcRequest(params.ReqId)
-		if req.Handler != nil {
-			_ = AsyncWrap(req.FromActorId, func(ctx interface{}) {
-				if err := req.Handler(ctx, params, nil); err != nil {
-					logger.ERR("handle rpc response failed: ", err)
-				}
-			})
+	if req := m.rpcRequests[rpcKey{nodeId: params.NodeId, reqId: params.ReqId}]; req != nil {
+		// stale response of another stream must not complete this request
+		if params.OriginNodeId != cluster.GetCurrentNodeId() {
+			logger.ERR("reject rpc response: ", params.ReqId, " origin: ", params.OriginNodeId, " from: ", params.NodeId)
+			atomic.AddInt64(&rpcStats.Rejected, 1)
+			return
+		}
+		atomic.AddInt64(&rpcStats.Responses, 1)
+		m.delRpcRequest(req)
+		if params.Err != nil {
+			m.response(req, nil, params.Err)
 		} else {
-			req.Req.Response(params, nil)
+			m.response(req, params, nil)
 		}
 	}
 }
 
-var ErrTimeout = errors.New("rpc timeout")
+var ErrTimeout error = api.NewError(api.ErrCodeTimeout, "rpc timeout")
 
 func (m *RpcMgr) rpcTimeout(req *RpcRequest) {
 	logger.ERR("Rpc timeout: ", req)
-	m.delRpcRequest(req.ReqId)
-	if req.Handler != nil {
+	atomic.AddInt64(&rpcStats.Timeouts, 1)
+	m.delRpcRequest(req)
+	m.response(req, nil, ErrTimeout)
+}
+
+func (m *RpcMgr) response(req *RpcRequest, rsp interface{}, err error) {
+	switch {
+	case req.Handler != nil:
 		_ = AsyncWrap(req.FromActorId, func(ctx interface{}) {
-			if err := req.Handler(ctx, nil, ErrTimeout); err != nil {
+			if err := req.Handler(ctx, rsp, err); err != nil {
 				logger.ERR("handle rpc response failed: ", err)
 			}
 		})
-	} else {
-		req.Req.Response(nil, ErrTimeout)
+	case req.done != nil:
+		req.done <- &rpcResult{rsp: rsp, err: err}
+	case req.Req != nil:
+		req.Req.Response(rsp, err)
 	}
 }
 
@@ -175,17 +206,64 @@ type AddRpcParams struct {
 	rpcRequest *RpcRequest
 }
 
+type cancelRpcParams struct {
+	key rpcKey
+}
+
+// rpc requests waiting for response
+func RpcInflight() int64 {
+	return atomic.LoadInt64(&rpcStats.Requests)
+}
+
 func (m *RpcMgr) addRpcRequest(params *AddRpcParams) {
-	m.rpcRequests[params.rpcRequest.ReqId] = params.rpcRequest
+	req := params.rpcRequest
+	if _, ok := m.rpcRequests[req.key()]; ok {
+		return
+	}
+	atomic.AddInt64(&rpcStats.Requests, 1)
+	req.expireAt = req.ExpireAt()
+	m.rpcRequests[req.key()] = req
+	heap.Push(&m.timeouts, req)
+	m.resetTimer()
 }
 
-func (m *RpcMgr) getRpcRequest(rpcReqId int32) *RpcRequest {
-	if req, ok := m.rpcRequests[rpcReqId]; ok {
-		return req
+func (m *RpcMgr) delRpcRequest(req *RpcRequest) {
+	if _, ok := m.rpcRequests[req.key()]; ok {
+		atomic.AddInt64(&rpcStats.Requests, -1)
+		delete(m.rpcRequests, req.key())
+		if req.index >= 0 {
+			heap.Remove(&m.timeouts, req.index)
+		}
 	}
-	return nil
 }
 
-func (m *RpcMgr) delRpcRequest(rpcReqId int32) {
-	delete(m.rpcRequests, rpcReqId)
+func (m *RpcMgr) checkTimeout() {
+	now := clock.Now().UnixNano()
+	for len(m.timeouts) > 0 && m.timeouts[0].expireAt <= now {
+		m.rpcTimeout(m.timeouts[0])
+	}
+	m.timerAt = 0
+	m.resetTimer()
+}
+
+// timer fires at the earliest expire time, removed requests only cause a spare check
+func (m *RpcMgr) resetTimer() {
+	if len(m.timeouts) == 0 {
+		return
+	}
+	at := m.timeouts[0].expireAt
+	if m.timerAt != 0 && m.timerAt <= at {
+		return
+	}
+	m.timerAt = at
+	delay := time.Duration(at - clock.Now().UnixNano())
+	if m.timer == nil {
+		m.timer = clock.AfterFunc(delay, func() {
+			if err := server.Cast(&CheckTimeoutParams{}); err != nil {
+				logger.ERR("rpc mgr check timeout failed: ", err)
+			}
+		})
+	} else {
+		m.timer.Reset(delay)
+	}
 }
@@ -0,0 +1,33 @@
+package actor
+
+import (
+	"github.com/mafei198/gactor/cluster"
+	rpcproto "github.com/mafei198/gactor/rpc_proto"
+	"testing"
+	"time"
+)
+
+func TestRpcRspOriginChecked(t *testing.T) {
+	m := &RpcMgr{}
+	_ = m.Init(nil)
+	defer m.Terminate("test")
+	request := &RpcRequest{
+		StreamAgentMsg: &rpcproto.StreamAgentMsg{ReqId: 1, OriginNodeId: cluster.GetCurrentNodeId()},
+		ToNodeId:       "remote",
+		Timeout:        time.Hour,
+		done:           make(chan *rpcResult, 1),
+	}
+	m.addRpcRequest(&AddRpcParams{rpcRequest: request})
+
+	// responses of other origins or without origin don't complete the request
+	for _, origin := range []string{"", "other"} {
+		m.rpcRsp(&RpcRspParams{ReqId: 1, NodeId: "remote", OriginNodeId: origin})
+		if len(request.done) != 0 {
+			t.Fatal("response of origin accepted: ", origin)
+		}
+	}
+	m.rpcRsp(&RpcRspParams{ReqId: 1, NodeId: "remote", OriginNodeId: cluster.GetCurrentNodeId()})
+	if len(request.done) != 1 {
+		t.Fatal("response not accepted")
+	}
+}
@@ -71,10 +71,39 @@ func (s *RpcStream) OnData(in *rpcproto.StreamAgentMsg) error {
 	}
 	msg, err := pbmsg.Decode(in.Data)
 	if err != nil {
-		return err
+		logger.ERR("RpcStream decode failed: ", in.ToActorId, err)
+		return s.responseError(in, api.NewError(api.ErrCodeDecode, err.Error()))
+	}
+	switch in.ReqType {
+	case api.ReqBroadcast:
+		if batch, ok := msg.(*rpcproto.BroadcastMsg); ok {
+			deliverBatch(batch)
+		}
+		return nil
+	case api.ReqPublish:
+		if publish, ok := msg.(*rpcproto.PublishMsg); ok {
+			deliverPublish(publish.Topic, publish.Data)
+		}
+		return nil
+	}
+	if deliverMonitorMsg(in.ToActorId, msg) {
+		return nil
 	}
 	request := api.NewRequest(rpcAgent, in.ReqType, in.ReqId, msg)
-	return Request(in.ToActorId, request)
+	request.Deadline = in.Deadline
+	if err = Request(in.ToActorId, request); err != nil {
+		logger.ERR("RpcStream request failed: ", in.ToActorId, err)
+		return s.responseError(in, api.ToError(err, api.ErrCodeActorStart))
+	}
+	return nil
+}
+
+// Caller of Call is notified immediately, stream is kept for other requests
+func (s *RpcStream) responseError(in *rpcproto.StreamAgentMsg, err *api.Error) error {
+	if in.ReqType != api.ReqCall {
+		return nil
+	}
+	return s.SendError(in.ReqId, in.FromActorId, in.OriginNodeId, err)
 }
 
 func (s *RpcStream) LocalRequest(in *RpcRequest) error {
@@ -82,21 +111,40 @@ func (s *RpcStream) LocalRequest(in *RpcRequest) error {
 		s:              s,
 		StreamAgentMsg: in.StreamAgentMsg,
 	}
+	if deliverMonitorMsg(in.ToActorId, in.Params) {
+		return nil
+	}
 	request := api.NewRequest(rpcAgent, in.ReqType, in.ReqId, in.Params)
+	request.Deadline = in.Deadline
 	return Request(in.ToActorId, request)
 }
 
-func (s *RpcStream) SendData(reqId int32, fromActorId string, data []byte) error {
+func (s *RpcStream) SendData(reqId int64, fromActorId, originNodeId string, data []byte) error {
+	return s.send(&rpcproto.StreamAgentRsp{
+		ReqId:        reqId,
+		FromActorId:  fromActorId,
+		Data:         data,
+		OriginNodeId: originNodeId,
+	})
+}
+
+func (s *RpcStream) SendError(reqId int64, fromActorId, originNodeId string, err *api.Error) error {
+	return s.send(&rpcproto.StreamAgentRsp{
+		ReqId:        reqId,
+		FromActorId:  fromActorId,
+		ErrCode:      err.Code,
+		ErrMsg:       err.Message,
+		OriginNodeId: originNodeId,
+	})
+}
+
+func (s *RpcStream) send(rsp *rpcproto.StreamAgentRsp) error {
 	if s.clientNodeId == cluster.GetCurrentNodeId() {
 		// 本地节点
-		rpcRspHandler(reqId, fromActorId, data)
+		rpcRspHandler(s.clientNodeId, rsp)
 		return nil
 	} else {
 		// 远程节点
-		return s.stream.Send(&rpcproto.StreamAgentRsp{
-			ReqId:       reqId,
-			FromActorId: fromActorId,
-			Data:        data,
-		})
+		return s.stream.Send(rsp)
 	}
 }
@@ -0,0 +1,77 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package actor
+
+import (
+	"sync/atomic"
+)
+
+// Pending rpc requests ordered by expire time
+type rpcTimeoutHeap []*RpcRequest
+
+func (h rpcTimeoutHeap) Len() int { return len(h) }
+
+func (h rpcTimeoutHeap) Less(i, j int) bool { return h[i].expireAt < h[j].expireAt }
+
+func (h rpcTimeoutHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *rpcTimeoutHeap) Push(x interface{}) {
+	req := x.(*RpcRequest)
+	req.index = len(*h)
+	*h = append(*h, req)
+}
+
+func (h *rpcTimeoutHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	req := old[n-1]
+	old[n-1] = nil
+	req.index = -1
+	*h = old[:n-1]
+	return req
+}
+
+type RpcStats struct {
+	Requests  int64 // requests waiting for response
+	Responses int64
+	Timeouts  int64
+	Rejected  int64 // responses not matching origin of request
+	Canceled  int64
+}
+
+var rpcStats RpcStats
+
+func GetRpcStats() RpcStats {
+	return RpcStats{
+		Requests:  atomic.LoadInt64(&rpcStats.Requests),
+		Responses: atomic.LoadInt64(&rpcStats.Responses),
+		Timeouts:  atomic.LoadInt64(&rpcStats.Timeouts),
+		Rejected:  atomic.LoadInt64(&rpcStats.Rejected),
+		Canceled:  atomic.LoadInt64(&rpcStats.Canceled),
+	}
+}
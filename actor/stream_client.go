@@ -47,7 +47,8 @@ type Stream struct {
 	RpcClient    proto.GameRpcServerClient
 }
 
-type RpcRspHandler func(reqId int32, fromActorId string, data []byte)
+// nodeId is the node sent rsp
+type RpcRspHandler func(nodeId string, rsp *proto.StreamAgentRsp)
 
 var rpcRspHandler RpcRspHandler
 
@@ -157,7 +158,7 @@ func startReceiver(stream *Stream) {
 				logger.ERR("AgentStream failed to receive : ", err)
 				break
 			}
-			rpcRspHandler(in.ReqId, in.FromActorId, in.Data)
+			rpcRspHandler(stream.GameAppId, in)
 		}
 		gameStreamsMap.Delete(stream.GameAppId)
 		err := stream.StreamClient.CloseSend()
@@ -36,6 +36,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -45,9 +46,10 @@ type RpcAgentServer struct{}
 var streamListener net.Listener
 var agents = &sync.Map{}
 
-func StartRpcServer() (int, error) {
+// Listen on host with a port in [minPort, maxPort], minPort 0 for random port.
+func StartRpcServer(host string, minPort, maxPort int) (int, error) {
 	var err error
-	streamListener, err = net.Listen("tcp4", ":0")
+	streamListener, err = listen(host, minPort, maxPort)
 	if err != nil {
 		logger.ERR("failed to listen: ", err)
 		return 0, err
@@ -62,6 +64,19 @@ func StartRpcServer() (int, error) {
 	return port, err
 }
 
+func listen(host string, minPort, maxPort int) (lis net.Listener, err error) {
+	if maxPort < minPort {
+		maxPort = minPort
+	}
+	for port := minPort; port <= maxPort; port++ {
+		lis, err = net.Listen("tcp4", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err == nil {
+			return
+		}
+	}
+	return
+}
+
 func AddLocalAgent() {
 	// 添加本地Agent
 	AddStreamAgent(cluster.GetCurrentNodeId(), &RpcStream{
@@ -124,3 +139,14 @@ func (s *RpcAgentServer) StartActor(ctx context.Context, in *proto.StartActorReq
 	_, err := StartActor(in.ActorId, &gen_server.Option{Timeout: timeout})
 	return &proto.StartActorRsp{Success: err == nil}, err
 }
+
+func (s *RpcAgentServer) MigrateActor(ctx context.Context, in *proto.MigrateActorReq) (*proto.MigrateActorRsp, error) {
+	var timeout time.Duration
+	if in.Timeout > 0 {
+		timeout = time.Duration(in.Timeout)
+	} else {
+		timeout = gen_server.GetTimeout()
+	}
+	err := startMigratedActor(in.ActorId, in.State, timeout)
+	return &proto.MigrateActorRsp{Success: err == nil}, err
+}
@@ -0,0 +1,142 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package actor
+
+import (
+	"fmt"
+	"github.com/mafei198/gactor/api"
+	"github.com/mafei198/gactor/clock"
+	"github.com/mafei198/goslib/gen_server"
+	"github.com/mafei198/goslib/logger"
+	"runtime/debug"
+	"time"
+)
+
+type SupervisorStrategy int
+
+const (
+	// panic again and crash the node, the default
+	SuperviseEscalate SupervisorStrategy = iota
+	// recover and rebuild the actor, the failed message is answered with api.ErrCodePanic
+	SuperviseRestart
+	// recover and stop the actor, it is started again by next message
+	SuperviseStop
+)
+
+const (
+	DefaultMaxRestarts   = 3
+	DefaultRestartWindow = 5 * time.Second
+)
+
+// Behavior implements Restartable to reset itself on restart, otherwise a new
+// Behavior is constructed, loaded from persistent state and started by OnStart.
+type Restartable interface {
+	OnRestart(server *Server, reason string) error
+}
+
+func (ins *Server) onPanic(r interface{}) error {
+	reason := fmt.Sprint(r)
+	logger.ERR("actor panic: ", ins.Meta.Uuid, " reason: ", reason, "\n", string(debug.Stack()))
+	err := api.NewError(api.ErrCodePanic, reason)
+	switch ins.Factory.Supervisor {
+	case SuperviseStop:
+		ins.crash("panic: " + reason)
+	case SuperviseRestart:
+		if !ins.allowRestart() {
+			ins.crash("restart intensity reached: " + reason)
+			return err
+		}
+		if restartErr := ins.restart(reason); restartErr != nil {
+			logger.ERR("actor restart failed: ", ins.Meta.Uuid, restartErr)
+			ins.crash("restart failed: " + restartErr.Error())
+		}
+	default:
+		panic(r)
+	}
+	return err
+}
+
+func (ins *Server) allowRestart() bool {
+	maxRestarts := ins.Factory.MaxRestarts
+	if maxRestarts <= 0 {
+		maxRestarts = DefaultMaxRestarts
+	}
+	window := ins.Factory.RestartWindow
+	if window <= 0 {
+		window = DefaultRestartWindow
+	}
+	now := clock.Now()
+	restarts := ins.restarts[:0]
+	for _, at := range ins.restarts {
+		if now.Sub(at) < window {
+			restarts = append(restarts, at)
+		}
+	}
+	ins.restarts = append(restarts, now)
+	return len(ins.restarts) <= maxRestarts
+}
+
+func (ins *Server) restart(reason string) error {
+	if hook, ok := ins.Actor.(Restartable); ok {
+		return hook.OnRestart(ins, reason)
+	}
+	// tickers, timers and subscriptions of the failed Behavior are dropped,
+	// persistent timers are restored and OnStart sets up the rest again
+	for _, ticker := range ins.tickers {
+		ticker.stop()
+	}
+	ins.tickers = nil
+	ins.stopTimers()
+	ins.timers = nil
+	ins.unsubscribeAll()
+	ins.StartTicker(ins.Factory.idleCheckInterval(), &activeCheckParams{})
+
+	ins.Actor = ins.Factory.Constructor()
+	if err := ins.restoreState(); err != nil {
+		return err
+	}
+	if err := ins.restoreTimers(); err != nil {
+		return err
+	}
+	return ins.Actor.OnStart(ins)
+}
+
+// State of panicked handler may be half updated, it's never saved and the
+// actor is started again from last saved state.
+func (ins *Server) crash(reason string) {
+	ins.crashed = true
+	ins.stopAsync(reason)
+}
+
+// actor can't stop itself inside handler
+func (ins *Server) stopAsync(reason string) {
+	actorId := ins.Meta.Uuid
+	go func() {
+		if err := gen_server.Stop(actorId, reason); err != nil {
+			logger.ERR("stop actor failed: ", actorId, err)
+			return
+		}
+		_ = gen_server.Cast(actorMgrId, &delActorParams{actorId: actorId})
+	}()
+}
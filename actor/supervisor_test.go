@@ -0,0 +1,47 @@
+package actor
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/mafei198/gactor/api"
+	"github.com/mafei198/gactor/storage"
+	"github.com/mafei198/goslib/gen_server"
+	"github.com/mafei198/goslib/pbmsg"
+	"testing"
+	"time"
+)
+
+type crashBehavior struct{ value string }
+
+func (b *crashBehavior) OnStart(server *Server) error { return nil }
+func (b *crashBehavior) OnStop(reason string) error   { return nil }
+func (b *crashBehavior) Load(data []byte) error       { b.value = string(data); return nil }
+func (b *crashBehavior) Save() ([]byte, error)        { return []byte(b.value), nil }
+func (b *crashBehavior) Dirty() bool                  { return b.value != "" }
+
+func TestPanicStateNotSaved(t *testing.T) {
+	startLocalNode(t)
+	pbmsg.Register(func() proto.Message { return &wrappers.BytesValue{} })
+	store := storage.NewMemoryStorage()
+	factory := NewFactory(func() Behavior { return &crashBehavior{} })
+	factory.Storage = store
+	factory.Supervisor = SuperviseStop
+	factory.Register(&wrappers.BytesValue{}, func(req *api.Request) proto.Message {
+		req.Ctx.(*crashBehavior).value = string(req.Params.(*wrappers.BytesValue).Value)
+		panic("boom")
+	})
+	actorId := addActor(t, factory, true)
+	if err := RpcCast(actorId, &wrappers.BytesValue{Value: []byte("half")}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for gen_server.Exists(actorId) {
+		if time.Now().After(deadline) {
+			t.Fatal("panicked actor not stopped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if data, err := store.Load(actorId); err != nil || data != nil {
+		t.Fatal("state of panicked handler saved: ", string(data), err)
+	}
+}
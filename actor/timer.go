@@ -0,0 +1,247 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package actor
+
+import (
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/mafei198/gactor/clock"
+	"github.com/mafei198/goslib/logger"
+	"github.com/mafei198/goslib/pbmsg"
+	"github.com/rs/xid"
+	"time"
+)
+
+type TimerRef string
+
+// Persistent timer stored with Meta, restored when actor started again or migrated
+type TimerMeta struct {
+	Ref    TimerRef `json:"ref"`
+	FireAt int64    `json:"fire_at"` // unix milli
+	Cron   string   `json:"cron,omitempty"`
+	Data   []byte   `json:"data"` // pbmsg encoded
+}
+
+type actorTimer struct {
+	ref        TimerRef
+	msg        interface{}
+	cron       *CronSchedule
+	spec       string
+	fireAt     time.Time
+	persistent bool
+	timer      clock.Timer
+}
+
+type timerParams struct{ ref TimerRef }
+
+var errTimerNoNext = errors.New("cron schedule has no next time")
+
+// Deliver msg to the actor itself through its routes after d
+func (ins *Server) SendAfter(d time.Duration, msg interface{}) TimerRef {
+	t := &actorTimer{ref: newTimerRef(), msg: msg, fireAt: clock.Now().Add(d)}
+	ins.addTimer(t)
+	return t.ref
+}
+
+// SendAfter surviving sleep and migration, msg must be registered to pbmsg
+func (ins *Server) SendAfterPersistent(d time.Duration, msg proto.Message) (TimerRef, error) {
+	t := &actorTimer{ref: newTimerRef(), msg: msg, fireAt: clock.Now().Add(d), persistent: true}
+	ins.addTimer(t)
+	if err := ins.saveTimers(); err != nil {
+		ins.CancelTimer(t.ref)
+		return "", err
+	}
+	return t.ref, nil
+}
+
+// Deliver msg repeatedly by cron spec, see ParseCron
+func (ins *Server) Schedule(spec string, msg proto.Message, persistent bool) (TimerRef, error) {
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return "", err
+	}
+	fireAt := schedule.Next(clock.Now())
+	if fireAt.IsZero() {
+		return "", errTimerNoNext
+	}
+	t := &actorTimer{
+		ref:        newTimerRef(),
+		msg:        msg,
+		cron:       schedule,
+		spec:       spec,
+		fireAt:     fireAt,
+		persistent: persistent,
+	}
+	ins.addTimer(t)
+	if persistent {
+		if err := ins.saveTimers(); err != nil {
+			ins.CancelTimer(t.ref)
+			return "", err
+		}
+	}
+	return t.ref, nil
+}
+
+// Return false if timer already fired or canceled
+func (ins *Server) CancelTimer(ref TimerRef) bool {
+	t, ok := ins.timers[ref]
+	if !ok {
+		return false
+	}
+	t.timer.Stop()
+	delete(ins.timers, ref)
+	if t.persistent {
+		if err := ins.saveTimers(); err != nil {
+			logger.ERR("save timers failed: ", ins.Meta.Uuid, err)
+		}
+	}
+	return true
+}
+
+func newTimerRef() TimerRef {
+	return TimerRef(xid.New().String())
+}
+
+func (ins *Server) addTimer(t *actorTimer) {
+	if ins.timers == nil {
+		ins.timers = map[TimerRef]*actorTimer{}
+	}
+	ins.timers[t.ref] = t
+	ins.armTimer(t)
+}
+
+func (ins *Server) armTimer(t *actorTimer) {
+	actorId := ins.Meta.Uuid
+	ref := t.ref
+	t.timer = clock.AfterFunc(clock.Until(t.fireAt), func() {
+		if err := Cast(actorId, &timerParams{ref: ref}); err != nil {
+			logger.ERR("fire timer failed: ", actorId, ref, err)
+		}
+	})
+}
+
+func (ins *Server) fireTimer(ref TimerRef) {
+	t, ok := ins.timers[ref]
+	// persistent timers are restored on target node after migrated
+	if !ok || ins.migratedTo != "" {
+		return
+	}
+	if t.cron != nil {
+		t.fireAt = t.cron.Next(clock.Now())
+		if t.fireAt.IsZero() {
+			delete(ins.timers, ref)
+		} else {
+			ins.armTimer(t)
+		}
+	} else {
+		delete(ins.timers, ref)
+	}
+	if t.persistent {
+		if err := ins.saveTimers(); err != nil {
+			logger.ERR("save timers failed: ", ins.Meta.Uuid, err)
+		}
+	}
+	ins.handleCast(t.msg)
+}
+
+// stop running timers, persistent ones are kept in Meta
+func (ins *Server) stopTimers() {
+	for _, t := range ins.timers {
+		t.timer.Stop()
+	}
+}
+
+func (ins *Server) restoreTimers() error {
+	for _, tm := range ins.Meta.Timers {
+		msg, err := pbmsg.Decode(tm.Data)
+		if err != nil {
+			return err
+		}
+		t := &actorTimer{
+			ref:        tm.Ref,
+			msg:        msg,
+			spec:       tm.Cron,
+			fireAt:     time.Unix(0, tm.FireAt*int64(time.Millisecond)),
+			persistent: true,
+		}
+		if tm.Cron != "" {
+			if t.cron, err = ParseCron(tm.Cron); err != nil {
+				return err
+			}
+		}
+		// overdue timers fire immediately
+		ins.addTimer(t)
+	}
+	return nil
+}
+
+func (ins *Server) persistentTimers() ([]*TimerMeta, error) {
+	var timers []*TimerMeta
+	for _, t := range ins.timers {
+		if !t.persistent {
+			continue
+		}
+		data, err := pbmsg.Encode(t.msg)
+		if err != nil {
+			return nil, err
+		}
+		timers = append(timers, &TimerMeta{
+			Ref:    t.ref,
+			FireAt: t.fireAt.UnixNano() / int64(time.Millisecond),
+			Cron:   t.spec,
+			Data:   data,
+		})
+	}
+	return timers, nil
+}
+
+const maxSaveTimersRetry = 3
+
+var errSaveTimersConflict = errors.New("save timers conflict")
+
+// CAS Meta with persistent timers, retry on concurrent update
+func (ins *Server) saveTimers() error {
+	timers, err := ins.persistentTimers()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < maxSaveTimersRetry; i++ {
+		meta, err := GetMeta(ins.Meta.Uuid)
+		if err != nil {
+			return err
+		}
+		updating := *meta
+		updating.Timers = timers
+		revision := updating.ModRevision
+		saved, err := setToEtcd(&updating)
+		if err != nil {
+			return err
+		}
+		if updating.ModRevision != revision {
+			ins.Meta = saved
+			return nil
+		}
+	}
+	return errSaveTimersConflict
+}
@@ -0,0 +1,97 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package api
+
+import (
+	"context"
+	"fmt"
+)
+
+// Error codes carried by StreamAgentRsp, 0 means success
+const (
+	ErrCodeNone int32 = iota
+	ErrCodeUnknown
+	ErrCodeRouteNotFound
+	ErrCodeActorStart
+	ErrCodeLocation
+	ErrCodePanic
+	ErrCodeTimeout
+	ErrCodeExpired
+	ErrCodeDecode
+	ErrCodeHandler
+)
+
+// Error returned by remote node
+type Error struct {
+	Code    int32
+	Message string
+}
+
+func NewError(code int32, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gactor error %d: %s", e.Code, e.Message)
+}
+
+// Retryable errors are caused by placement, the request is not handled by
+// target actor and can be sent again. A timed out request may have been
+// handled, it's not retryable.
+func (e *Error) Retryable() bool {
+	switch e.Code {
+	case ErrCodeActorStart, ErrCodeLocation:
+		return true
+	}
+	return false
+}
+
+func IsRetryable(err error) bool {
+	if e, ok := err.(*Error); ok {
+		return e.Retryable()
+	}
+	return false
+}
+
+// Convert err to *Error, unknown errors are tagged with defaultCode
+func ToError(err error, defaultCode int32) *Error {
+	switch err {
+	case nil:
+		return nil
+	case context.DeadlineExceeded, context.Canceled:
+		return NewError(ErrCodeExpired, err.Error())
+	}
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	return NewError(defaultCode, err.Error())
+}
+
+// Rebuild error from wire
+func FromCode(code int32, message string) error {
+	if code == ErrCodeNone {
+		return nil
+	}
+	return NewError(code, message)
+}
@@ -25,23 +25,25 @@ package api
 
 import (
 	"errors"
+	"github.com/mafei198/gactor/clock"
 	"github.com/mafei198/goslib/logger"
 	"github.com/mafei198/goslib/pbmsg"
-	"time"
 )
 
 type Request struct {
 	Ctx       interface{}
 	Agent     Agent       // connection agent
-	ReqId     int32       // request auto incr id
+	ReqId     int64       // request auto incr id
 	ReqType   int32       // request type
 	Params    interface{} // request params
 	Responsed bool        // is already responsed
 	CreatedAt int64       // created at
+	Deadline  int64       // unix nano, 0 means no deadline
 }
 
 type Agent interface {
-	SendData(reqId int32, data []byte) error
+	SendData(reqId int64, data []byte) error
+	SendError(reqId int64, err *Error) error
 	GetActorId() string
 	Close(reason string) error
 	GetUuid() string
@@ -50,28 +52,35 @@ type Agent interface {
 const (
 	ReqCast = iota
 	ReqCall
+	ReqBroadcast // batched cast to actors of a node
+	ReqPublish   // topic message to subscribers of a node
 )
 
 var (
-	ErrRouteNotFound = errors.New("route not found")
+	ErrRouteNotFound error = NewError(ErrCodeRouteNotFound, "route not found")
 )
 
 func NewLocalRequest(reqType int32, params interface{}) *Request {
 	return NewRequest(nil, reqType, 0, params)
 }
 
-func NewRequest(agent Agent, reqType int32, reqId int32, params interface{}) *Request {
+func NewRequest(agent Agent, reqType int32, reqId int64, params interface{}) *Request {
 	request := &Request{
 		Responsed: false,
 		Agent:     agent,
 		ReqId:     reqId,
 		Params:    params,
 		ReqType:   reqType,
-		CreatedAt: time.Now().UnixNano(),
+		CreatedAt: clock.Now().UnixNano(),
 	}
 	return request
 }
 
+// Caller gave up waiting, the request should be dropped.
+func (req *Request) Expired() bool {
+	return req.Deadline > 0 && clock.Now().UnixNano() >= req.Deadline
+}
+
 func (req *Request) GetParams() interface{} {
 	return req.Params
 }
@@ -84,7 +93,7 @@ func (req *Request) Response(msg interface{}) error {
 	}
 
 	req.Responsed = true
-	used := (time.Now().UnixNano() - req.CreatedAt) / 100000
+	used := (clock.Now().UnixNano() - req.CreatedAt) / 100000
 
 	switch req.ReqType {
 	case ReqCast:
@@ -100,6 +109,18 @@ func (req *Request) Response(msg interface{}) error {
 	return nil
 }
 
+// Response err to caller, only Call waits for it
+func (req *Request) ResponseError(err error) error {
+	if req.Responsed {
+		return responsedErr
+	}
+	req.Responsed = true
+	if req.ReqType != ReqCall || req.Agent == nil {
+		return nil
+	}
+	return req.Agent.SendError(req.ReqId, ToError(err, ErrCodeHandler))
+}
+
 func (req *Request) GetAgent() Agent {
 	return req.Agent
 }
@@ -0,0 +1,109 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Source of time of actors, rpc timeouts and idle expiry, replaced by a Fake
+// clock in tests and simulations to advance virtual time.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type Timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+var Real Clock = realClock{}
+
+var (
+	lock    = &sync.RWMutex{}
+	current = Real
+)
+
+// Replace clock, clocks of running tickers and timers are not changed
+func Set(c Clock) {
+	lock.Lock()
+	defer lock.Unlock()
+	current = c
+}
+
+func Get() Clock {
+	lock.RLock()
+	defer lock.RUnlock()
+	return current
+}
+
+func Now() time.Time {
+	return Get().Now()
+}
+
+func Since(t time.Time) time.Duration {
+	return Now().Sub(t)
+}
+
+func Until(t time.Time) time.Duration {
+	return t.Sub(Now())
+}
+
+func NewTicker(d time.Duration) Ticker {
+	return Get().NewTicker(d)
+}
+
+func AfterFunc(d time.Duration, f func()) Timer {
+	return Get().AfterFunc(d, f)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{time.NewTicker(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t *realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
@@ -0,0 +1,173 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Virtual clock, time moves only by Advance, which fires due timers and
+// tickers in time order. AfterFunc callbacks run in the goroutine of Advance.
+type Fake struct {
+	mutex   sync.Mutex
+	now     time.Time
+	seq     int64
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	at     time.Time
+	seq    int64 // keeps order of waiters due at the same time
+	period time.Duration
+	f      func()
+	ch     chan time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (c *Fake) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	w := &fakeWaiter{period: d, ch: make(chan time.Time, 1)}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.add(w, d)
+	return &fakeTicker{c: c, w: w}
+}
+
+func (c *Fake) AfterFunc(d time.Duration, f func()) Timer {
+	w := &fakeWaiter{f: f}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.add(w, d)
+	return &fakeTimer{c: c, w: w}
+}
+
+// Move time forward by d, firing everything due until then
+func (c *Fake) Advance(d time.Duration) {
+	c.mutex.Lock()
+	end := c.now.Add(d)
+	c.mutex.Unlock()
+	for {
+		c.mutex.Lock()
+		if len(c.waiters) == 0 || c.waiters[0].at.After(end) {
+			c.now = end
+			c.mutex.Unlock()
+			return
+		}
+		w := c.waiters[0]
+		c.waiters = c.waiters[1:]
+		if w.at.After(c.now) {
+			c.now = w.at
+		}
+		now := c.now
+		if w.period > 0 {
+			c.add(w, w.period)
+		}
+		c.mutex.Unlock()
+		if w.f != nil {
+			w.f()
+		} else {
+			select {
+			case w.ch <- now:
+			default:
+			}
+		}
+	}
+}
+
+// Number of pending timers and tickers
+func (c *Fake) Waiters() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.waiters)
+}
+
+func (c *Fake) add(w *fakeWaiter, d time.Duration) {
+	c.seq++
+	w.at = c.now.Add(d)
+	w.seq = c.seq
+	i := sort.Search(len(c.waiters), func(i int) bool {
+		other := c.waiters[i]
+		return other.at.After(w.at) || (other.at.Equal(w.at) && other.seq > w.seq)
+	})
+	c.waiters = append(c.waiters, nil)
+	copy(c.waiters[i+1:], c.waiters[i:])
+	c.waiters[i] = w
+}
+
+func (c *Fake) remove(w *fakeWaiter) bool {
+	for i, other := range c.waiters {
+		if other == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTicker struct {
+	c *Fake
+	w *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.w.ch
+}
+
+func (t *fakeTicker) Stop() {
+	t.c.mutex.Lock()
+	defer t.c.mutex.Unlock()
+	t.c.remove(t.w)
+}
+
+type fakeTimer struct {
+	c *Fake
+	w *fakeWaiter
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mutex.Lock()
+	defer t.c.mutex.Unlock()
+	return t.c.remove(t.w)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.c.mutex.Lock()
+	defer t.c.mutex.Unlock()
+	active := t.c.remove(t.w)
+	t.c.add(t.w, d)
+	return active
+}
@@ -0,0 +1,49 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeAdvance(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := NewFake(start)
+	var fired []time.Time
+	c.AfterFunc(3*time.Second, func() { fired = append(fired, c.Now()) })
+	stopped := c.AfterFunc(2*time.Second, func() { t.Fatal("stopped timer fired") })
+	c.AfterFunc(time.Second, func() { fired = append(fired, c.Now()) })
+	if !stopped.Stop() {
+		t.Fatal("stop pending timer failed")
+	}
+	c.Advance(5 * time.Second)
+	if len(fired) != 2 || !fired[0].Equal(start.Add(time.Second)) || !fired[1].Equal(start.Add(3*time.Second)) {
+		t.Fatal("unexpected fired: ", fired)
+	}
+	if !c.Now().Equal(start.Add(5 * time.Second)) {
+		t.Fatal("unexpected now: ", c.Now())
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	c := NewFake(time.Unix(0, 0))
+	ticker := c.NewTicker(time.Second)
+	c.Advance(500 * time.Millisecond)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticked early")
+	default:
+	}
+	c.Advance(time.Second)
+	select {
+	case at := <-ticker.C():
+		if at.Unix() != 1 {
+			t.Fatal("unexpected tick: ", at)
+		}
+	default:
+		t.Fatal("not ticked")
+	}
+	ticker.Stop()
+	if c.Waiters() != 0 {
+		t.Fatal("ticker not removed")
+	}
+}
@@ -0,0 +1,139 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cluster
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const StrategyConsistentHash = "consistent_hash"
+
+// virtual nodes per weight
+const DefaultVirtualNodes = 160
+
+// max cached rings, rings are rebuilt only when the node set changed
+const maxCachedRings = 16
+
+func init() {
+	RegisterStrategy(StrategyConsistentHash, NewConsistentHash(DefaultVirtualNodes))
+}
+
+type HashRing struct {
+	hashes []uint32
+	owners map[uint32]*Node
+}
+
+// Every node owns virtualNodes * Node.Weight points of the ring.
+func NewHashRing(nodes []*Node, virtualNodes int) *HashRing {
+	ring := &HashRing{
+		owners: map[uint32]*Node{},
+	}
+	for _, node := range nodes {
+		replicas := virtualNodes * int(node.GetWeight())
+		for i := 0; i < replicas; i++ {
+			hash := hashKey(node.Uuid + "#" + strconv.Itoa(i))
+			owner, ok := ring.owners[hash]
+			if !ok {
+				ring.hashes = append(ring.hashes, hash)
+			} else if owner.Uuid < node.Uuid {
+				// collision, smaller uuid wins, so every node builds the same ring
+				continue
+			}
+			ring.owners[hash] = node
+		}
+	}
+	sort.Slice(ring.hashes, func(i, j int) bool {
+		return ring.hashes[i] < ring.hashes[j]
+	})
+	return ring
+}
+
+// Get the first node clockwise from key's hash.
+func (r *HashRing) Get(key string) *Node {
+	if len(r.hashes) == 0 {
+		return nil
+	}
+	hash := hashKey(key)
+	idx := sort.Search(len(r.hashes), func(i int) bool {
+		return r.hashes[i] >= hash
+	})
+	if idx == len(r.hashes) {
+		idx = 0
+	}
+	return r.owners[r.hashes[idx]]
+}
+
+// ketama style hash, crc32 spreads similar keys badly
+func hashKey(key string) uint32 {
+	sum := md5.Sum([]byte(key))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+// Place actor by hash of actor id, adding or removing one node only relocates
+// about 1/N of the actors.
+type ConsistentHash struct {
+	VirtualNodes int
+
+	mutex sync.Mutex
+	rings map[string]*HashRing
+}
+
+func NewConsistentHash(virtualNodes int) *ConsistentHash {
+	return &ConsistentHash{
+		VirtualNodes: virtualNodes,
+		rings:        map[string]*HashRing{},
+	}
+}
+
+func (s *ConsistentHash) Choose(actorId string, nodes []*Node) *Node {
+	return s.ring(nodes).Get(actorId)
+}
+
+func (s *ConsistentHash) ring(nodes []*Node) *HashRing {
+	key := ringKey(nodes)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if ring, ok := s.rings[key]; ok {
+		return ring
+	}
+	if len(s.rings) >= maxCachedRings {
+		s.rings = map[string]*HashRing{}
+	}
+	ring := NewHashRing(nodes, s.VirtualNodes)
+	s.rings[key] = ring
+	return ring
+}
+
+func ringKey(nodes []*Node) string {
+	keys := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		keys = append(keys, node.Uuid+":"+strconv.Itoa(int(node.GetWeight())))
+	}
+	return strings.Join(keys, ",")
+}
@@ -0,0 +1,50 @@
+package cluster
+
+import (
+	"strconv"
+	"testing"
+)
+
+func testNodes(n int) []*Node {
+	nodes := make([]*Node, 0, n)
+	for i := 0; i < n; i++ {
+		nodes = append(nodes, &Node{Uuid: "node" + strconv.Itoa(i)})
+	}
+	return nodes
+}
+
+func TestHashRingMinimalMovement(t *testing.T) {
+	nodes := testNodes(10)
+	before := NewHashRing(nodes, DefaultVirtualNodes)
+	after := NewHashRing(append(nodes, &Node{Uuid: "node10"}), DefaultVirtualNodes)
+	actors := 100000
+	moved := 0
+	for i := 0; i < actors; i++ {
+		id := "Actor:" + strconv.Itoa(i)
+		from, to := before.Get(id), after.Get(id)
+		if from.Uuid != to.Uuid {
+			moved++
+			if to.Uuid != "node10" {
+				t.Fatalf("actor %s moved between old nodes: %s -> %s", id, from.Uuid, to.Uuid)
+			}
+		}
+	}
+	// expect about 1/11 of actors moved
+	if moved < actors/20 || moved > actors/6 {
+		t.Errorf("moved %d of %d actors", moved, actors)
+	}
+}
+
+func TestHashRingWeight(t *testing.T) {
+	nodes := testNodes(2)
+	nodes[1].Weight = 3
+	ring := NewHashRing(nodes, DefaultVirtualNodes)
+	counts := map[string]int{}
+	for i := 0; i < 40000; i++ {
+		counts[ring.Get("Actor:"+strconv.Itoa(i)).Uuid]++
+	}
+	ratio := float64(counts["node1"]) / float64(counts["node0"])
+	if ratio < 2 || ratio > 4 {
+		t.Errorf("weighted ratio %f, counts: %v", ratio, counts)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cluster
+
+// Version of the key layout, bumped when the layout changes
+const KeySchemaVersion = "v1"
+
+const DefaultClusterName = "default"
+
+// Name of current cluster, environments sharing one etcd use different names
+var clusterName = DefaultClusterName
+
+func GetClusterName() string {
+	return clusterName
+}
+
+func SetClusterName(name string) {
+	if name == "" {
+		name = DefaultClusterName
+	}
+	clusterName = name
+}
+
+// All keys of current cluster are under /<cluster>/<version>/
+func KeyRoot() string {
+	return KeyRootOf(clusterName)
+}
+
+func KeyRootOf(name string) string {
+	return "/" + name + "/" + KeySchemaVersion + "/"
+}
+
+func MetaPrefix() string {
+	return KeyRoot() + "metas/"
+}
+
+func MetaKey(actorId string) string {
+	return MetaPrefix() + actorId
+}
+
+func NodePrefix() string {
+	return KeyRoot() + "nodes/"
+}
+
+func NodeKey(nodeId string) string {
+	return NodePrefix() + nodeId
+}
+
+func DaemonPrefix() string {
+	return KeyRoot() + "daemons/"
+}
+
+func DaemonKey(actorId string) string {
+	return DaemonPrefix() + actorId
+}
+
+func GroupPrefix() string {
+	return KeyRoot() + "groups/"
+}
+
+func TopicPrefix() string {
+	return KeyRoot() + "topics/"
+}
+
+func SnapshotPrefix() string {
+	return KeyRoot() + "snapshots/"
+}
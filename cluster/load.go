@@ -0,0 +1,78 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cluster
+
+// Load of node, published with Node on every keepalive tick
+type Load struct {
+	Actors      map[string]int32 // actor amount by category
+	Goroutines  int32
+	HeapAlloc   uint64 // bytes of allocated heap objects
+	HeapInuse   uint64 // bytes in in-use spans
+	Mailbox     int64  // messages waiting to be handled by actors
+	RpcInflight int64  // rpc requests waiting for response
+	RpcTimeouts int64  // rpc requests timed out since started
+	UpdatedAt   int64
+}
+
+const StrategyLeastMemory = "least_memory"
+
+func init() {
+	RegisterStrategy(StrategyLeastMemory, &LeastMemory{})
+}
+
+// List nodes of role sorted by Uuid, all nodes if role is blank.
+func ListNodes(role string) []*Node {
+	nodes, roleNodes := collectNodes(role, true)
+	if role == "" {
+		return nodes
+	}
+	return roleNodes
+}
+
+func GetLoad(nodeId string) (*Load, bool) {
+	if node, ok := FindNode(nodeId); ok && node.Load != nil {
+		return node.Load, true
+	}
+	return nil, false
+}
+
+// Node with the lowest heap in use, nodes not reported load yet first
+type LeastMemory struct{}
+
+func (s *LeastMemory) Choose(actorId string, nodes []*Node) *Node {
+	chosen := nodes[0]
+	for _, node := range nodes[1:] {
+		if heapInuse(node) < heapInuse(chosen) {
+			chosen = node
+		}
+	}
+	return chosen
+}
+
+func heapInuse(node *Node) uint64 {
+	if node.Load == nil {
+		return 0
+	}
+	return node.Load.HeapInuse
+}
@@ -41,7 +41,10 @@ type Node struct {
 	RpcHost  string
 	RpcPort  string
 	Ccu      int32
+	Weight   int32 // used by weighted strategies, 1 if not set
 	ActiveAt int64
+	Load     *Load
+	Draining bool // draining node accepts no new actors
 }
 
 // Current server uuid
@@ -65,7 +68,7 @@ func FindNode(uuid string) (*Node, bool) {
 }
 
 func NewNode(role, rpcHost, rpcPort string) *Node {
-	uuid := NodePrefix() + xid.New().String()
+	uuid := "Node:" + xid.New().String()
 	node := &Node{
 		Uuid:     uuid,
 		Role:     role,
@@ -77,36 +80,52 @@ func NewNode(role, rpcHost, rpcPort string) *Node {
 	return node
 }
 
+func (n *Node) GetWeight() int32 {
+	if n.Weight <= 0 {
+		return 1
+	}
+	return n.Weight
+}
+
+// Choose node with default strategy
 func ChooseNode(role string) *Node {
-	nodes := make([]*Node, 0)
-	roleNodes := make([]*Node, 0)
+	return ChooseNodeBy("", role, "")
+}
+
+// Choose node with strategy among nodes of role, fallback to all nodes
+// if there is no node of role. Draining nodes are never chosen. Candidates
+// are sorted by Uuid, so ties between equally loaded nodes go by Uuid order.
+func ChooseNodeBy(strategy, role, actorId string) *Node {
+	nodes, roleNodes := collectNodes(role, false)
+	s := GetStrategy(strategy)
+	if len(roleNodes) > 0 {
+		return s.Choose(actorId, roleNodes)
+	}
+	if len(nodes) > 0 {
+		return s.Choose(actorId, nodes)
+	}
+	return nil
+}
+
+func collectNodes(role string, withDraining bool) (nodes, roleNodes []*Node) {
 	CacheNodes.Range(func(key, value interface{}) bool {
 		node := value.(*Node)
+		if node.Draining && !withDraining {
+			return true
+		}
 		nodes = append(nodes, node)
 		if node.Role == role {
 			roleNodes = append(roleNodes, node)
 		}
 		return true
 	})
-	if node := recommend(roleNodes); node != nil {
-		return node
-	}
-	return recommend(nodes)
+	sortNodes(nodes)
+	sortNodes(roleNodes)
+	return
 }
 
-func recommend(nodes []*Node) *Node {
-	length := len(nodes)
-	if length == 0 {
-		return nil
-	} else if length == 1 {
-		return nodes[0]
-	}
+func sortNodes(nodes []*Node) {
 	sort.Slice(nodes, func(i, j int) bool {
-		return nodes[i].Ccu <= nodes[j].Ccu
+		return nodes[i].Uuid < nodes[j].Uuid
 	})
-	return nodes[0]
-}
-
-func NodePrefix() string {
-	return "{gactor}.Node:"
 }
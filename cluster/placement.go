@@ -0,0 +1,109 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cluster
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// Choose node for actor, strategies must be registered with the same name
+// on every node, because an actor may be dispatched by any node.
+type PlacementStrategy interface {
+	// nodes is sorted by Uuid and never empty
+	Choose(actorId string, nodes []*Node) *Node
+}
+
+const (
+	StrategyLeastLoaded    = "least_loaded"
+	StrategyRoundRobin     = "round_robin"
+	StrategyWeightedRandom = "weighted_random"
+)
+
+const DefaultStrategy = StrategyLeastLoaded
+
+var strategies = &sync.Map{}
+
+func init() {
+	RegisterStrategy(StrategyLeastLoaded, &LeastLoaded{})
+	RegisterStrategy(StrategyRoundRobin, &RoundRobin{})
+	RegisterStrategy(StrategyWeightedRandom, &WeightedRandom{})
+}
+
+func RegisterStrategy(name string, strategy PlacementStrategy) {
+	strategies.Store(name, strategy)
+}
+
+// Get strategy by name, return default strategy if not registered.
+func GetStrategy(name string) PlacementStrategy {
+	if name == "" {
+		name = DefaultStrategy
+	}
+	if strategy, ok := strategies.Load(name); ok {
+		return strategy.(PlacementStrategy)
+	}
+	strategy, _ := strategies.Load(DefaultStrategy)
+	return strategy.(PlacementStrategy)
+}
+
+// Node with the lowest Ccu
+type LeastLoaded struct{}
+
+func (s *LeastLoaded) Choose(actorId string, nodes []*Node) *Node {
+	chosen := nodes[0]
+	for _, node := range nodes[1:] {
+		if node.Ccu < chosen.Ccu {
+			chosen = node
+		}
+	}
+	return chosen
+}
+
+type RoundRobin struct {
+	counter uint64
+}
+
+func (s *RoundRobin) Choose(actorId string, nodes []*Node) *Node {
+	n := atomic.AddUint64(&s.counter, 1)
+	return nodes[n%uint64(len(nodes))]
+}
+
+// Random node with probability proportional to Node.Weight
+type WeightedRandom struct{}
+
+func (s *WeightedRandom) Choose(actorId string, nodes []*Node) *Node {
+	var total int64
+	for _, node := range nodes {
+		total += int64(node.GetWeight())
+	}
+	n := rand.Int63n(total)
+	for _, node := range nodes {
+		n -= int64(node.GetWeight())
+		if n < 0 {
+			return node
+		}
+	}
+	return nodes[len(nodes)-1]
+}
@@ -0,0 +1,85 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Command gactor-migrate rewrites keys of the legacy layout into
+// /<cluster>/<version>/, stop all nodes before migrating.
+//
+//	gactor-migrate -endpoints 127.0.0.1:2379 -cluster production -snapshots snapshots/ -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/mafei198/gactor/cluster"
+	"github.com/mafei198/gactor/etcd"
+	"github.com/mafei198/gactor/registry"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	endpoints := flag.String("endpoints", "127.0.0.1:2379", "etcd endpoints, separated by comma")
+	namespace := flag.String("namespace", "", "etcd namespace of the keyspace")
+	clusterName := flag.String("cluster", cluster.DefaultClusterName, "cluster name of new layout")
+	snapshots := flag.String("snapshots", "", "prefixes of etcd storage snapshots, separated by comma")
+	deleteOld := flag.Bool("delete", false, "delete legacy keys after copied")
+	dryRun := flag.Bool("dry-run", false, "print keys to migrate only")
+	timeout := flag.Duration("timeout", time.Minute, "timeout of migration")
+	flag.Parse()
+
+	conf := etcd.DefaultConfig()
+	conf.Endpoints = strings.Split(*endpoints, ",")
+	conf.Namespace = *namespace
+	if err := etcd.StartWithConfig(conf); err != nil {
+		fmt.Fprintln(os.Stderr, "connect etcd failed:", err)
+		os.Exit(1)
+	}
+	r := registry.NewEtcdRegistry(etcd.Client)
+	defer r.Close()
+	cluster.SetClusterName(*clusterName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	var snapshotPrefixes []string
+	if *snapshots != "" {
+		snapshotPrefixes = strings.Split(*snapshots, ",")
+	}
+	result, err := migrate(ctx, r, snapshotPrefixes, *deleteOld, *dryRun, func(from, to string) {
+		if to == "" {
+			fmt.Println("skip", from)
+		} else {
+			fmt.Println(from, "=>", to)
+		}
+	})
+	if result != nil {
+		fmt.Printf("copied: %d existed: %d conflict: %d skipped: %d deleted: %d\n",
+			result.Copied, result.Existed, result.Conflict, result.Skipped, result.Deleted)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "migrate failed:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/mafei198/gactor/cluster"
+	"github.com/mafei198/gactor/registry"
+	"strings"
+)
+
+// Prefixes of the layout before /<cluster>/<version>/
+const (
+	legacyDaemonPrefix = "DadmonMetas:"
+	legacyNodePrefix   = "{gactor}.Node:"
+	legacyGroupPrefix  = "{gactor}.Group:"
+	legacyTopicPrefix  = "{gactor}.Topic:"
+)
+
+type migrateResult struct {
+	Copied   int // written to new layout
+	Existed  int // new key exists already, kept
+	Conflict int // new key exists with different value, legacy key kept
+	Skipped  int // not migrated: lease bound or unknown keys
+	Deleted  int // legacy keys deleted
+}
+
+// Copy legacy keys into layout of cluster.KeyRoot(), nodes and topics are
+// bound to node leases and registered again by nodes, so they are skipped.
+// Snapshots of EtcdStorage were written at root under their storage prefix,
+// they are moved under cluster.SnapshotPrefix() keeping the prefix.
+func migrate(ctx context.Context, r registry.Registry, snapshotPrefixes []string, deleteOld, dryRun bool, report func(from, to string)) (*migrateResult, error) {
+	kvs, _, err := r.GetPrefix(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	result := &migrateResult{}
+	for _, kv := range kvs {
+		if strings.HasPrefix(kv.Key, "/") {
+			continue
+		}
+		to := newKey(kv, snapshotPrefixes)
+		report(kv.Key, to)
+		if to == "" {
+			result.Skipped++
+			continue
+		}
+		if dryRun {
+			result.Copied++
+			continue
+		}
+		if current, ok, err := r.CompareAndSwap(ctx, to, 0, kv.Value); err != nil {
+			return result, err
+		} else if ok {
+			result.Copied++
+		} else if bytes.Equal(current.Value, kv.Value) {
+			result.Existed++
+		} else {
+			// written by new layout already, legacy key is left for inspection
+			result.Conflict++
+			continue
+		}
+		if deleteOld {
+			if err := r.Delete(ctx, kv.Key); err != nil {
+				return result, err
+			}
+			result.Deleted++
+		}
+	}
+	return result, nil
+}
+
+// key in new layout, blank if not migrated
+func newKey(kv *registry.KeyValue, snapshotPrefixes []string) string {
+	for _, prefix := range snapshotPrefixes {
+		if prefix != "" && strings.HasPrefix(kv.Key, prefix) {
+			return cluster.SnapshotPrefix() + kv.Key
+		}
+	}
+	switch {
+	case strings.HasPrefix(kv.Key, legacyDaemonPrefix):
+		return cluster.DaemonKey(strings.TrimPrefix(kv.Key, legacyDaemonPrefix))
+	case strings.HasPrefix(kv.Key, legacyGroupPrefix):
+		return cluster.GroupPrefix() + strings.TrimPrefix(kv.Key, legacyGroupPrefix)
+	case strings.HasPrefix(kv.Key, legacyNodePrefix), strings.HasPrefix(kv.Key, legacyTopicPrefix):
+		return ""
+	}
+	// metas were written at root with actor id as key
+	meta := &struct {
+		Uuid     string `json:"uuid"`
+		Category string `json:"category"`
+	}{}
+	if err := json.Unmarshal(kv.Value, meta); err != nil || meta.Uuid != kv.Key || meta.Category == "" {
+		return ""
+	}
+	return cluster.MetaKey(kv.Key)
+}
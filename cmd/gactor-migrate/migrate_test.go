@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"github.com/mafei198/gactor/cluster"
+	"github.com/mafei198/gactor/registry"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	r := registry.NewMemoryRegistry()
+	defer r.Close()
+	ctx := context.Background()
+	lease, _ := r.Grant(ctx, 60)
+	_ = r.Put(ctx, "Actor:a", []byte(`{"uuid":"Actor:a","category":"Player"}`), 0)
+	_ = r.Put(ctx, "DadmonMetas:Actor:d", []byte("Actor:d"), 0)
+	_ = r.Put(ctx, "{gactor}.Group:guild/Actor:a", nil, 0)
+	_ = r.Put(ctx, "{gactor}.Node:n1", []byte(`{}`), lease)
+	_ = r.Put(ctx, "snapshots/Actor:a", []byte("state"), 0)
+	_ = r.Put(ctx, "snapshots/Actor:b", []byte("old"), 0)
+	_ = r.Put(ctx, "/test/v1/snapshots/snapshots/Actor:b", []byte("new"), 0)
+	cluster.SetClusterName("test")
+	defer cluster.SetClusterName("")
+
+	result, err := migrate(ctx, r, []string{"snapshots/"}, true, false, func(from, to string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Copied != 4 || result.Conflict != 1 || result.Skipped != 1 || result.Deleted != 4 {
+		t.Fatal("unexpected result: ", *result)
+	}
+	for _, key := range []string{
+		"/test/v1/metas/Actor:a",
+		"/test/v1/daemons/Actor:d",
+		"/test/v1/groups/guild/Actor:a",
+		"/test/v1/snapshots/snapshots/Actor:a",
+		"snapshots/Actor:b",
+	} {
+		if kv, _ := r.Get(ctx, key); kv == nil {
+			t.Fatal("key not exists: ", key)
+		}
+	}
+	for _, key := range []string{"Actor:a", "snapshots/Actor:a"} {
+		if kv, _ := r.Get(ctx, key); kv != nil {
+			t.Fatal("legacy key not deleted: ", key)
+		}
+	}
+	if kv, _ := r.Get(ctx, "/test/v1/snapshots/snapshots/Actor:b"); kv == nil || string(kv.Value) != "new" {
+		t.Fatal("new snapshot overwritten: ", kv)
+	}
+}
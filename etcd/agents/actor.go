@@ -25,14 +25,16 @@ package agents
 
 import (
 	"context"
-	"github.com/coreos/etcd/mvcc/mvccpb"
 	"github.com/mafei198/gactor/actor"
 	"github.com/mafei198/gactor/cluster"
-	"github.com/mafei198/gactor/etcd"
-	"go.etcd.io/etcd/clientv3"
+	"github.com/mafei198/gactor/registry"
+	"github.com/mafei198/goslib/logger"
+	"strings"
 	"time"
 )
 
+// Keeps metas cached by actor package up to date with changes of all nodes,
+// stops when registry is closed
 type ActorAgent struct{}
 
 func NewActorAgent() *ActorAgent {
@@ -40,41 +42,49 @@ func NewActorAgent() *ActorAgent {
 }
 
 func (a *ActorAgent) Start() error {
-	var err error
-	go func() {
-		err = a.Watch()
-	}()
-	return err
-}
-
-func (a *ActorAgent) Watch() error {
-	metas := map[string]*actor.Meta{}
-	actor.CacheMetas = metas
-	ch := etcd.Client.Watch(context.Background(), cluster.NodePrefix(), clientv3.WithPrefix())
-	for result := range ch {
-		for _, event := range result.Events {
-			switch event.Type {
-			case mvccpb.PUT:
-				actor.StoreMeta(metas, event.Kv)
-			case mvccpb.DELETE:
-				actor.DelMetaCache(string(event.Kv.Key), event.Kv.ModRevision)
-			}
-		}
-	}
-	a.retryWatch()
+	go a.Watch()
 	return nil
 }
 
-func (a *ActorAgent) retryWatch() {
-	var err error
+func (a *ActorAgent) Watch() {
+	r := registry.Current
+	var revision int64
 	for {
-		go func() {
-			err = a.Watch()
-		}()
-		if err != nil {
-			time.Sleep(5 * time.Second)
-		} else {
-			break
+		from := int64(0)
+		if revision > 0 {
+			from = revision + 1
+		}
+		received := false
+		ch := r.Watch(context.Background(), cluster.MetaPrefix(), from)
+		for events := range ch {
+			received = true
+			for _, event := range events {
+				uuid := strings.TrimPrefix(event.Kv.Key, cluster.MetaPrefix())
+				switch event.Type {
+				case registry.EventPut:
+					actor.UpdateMetaCache(uuid, event.Kv)
+				case registry.EventDelete:
+					actor.InvalidateMetaCache(uuid, event.Kv.ModRevision)
+				}
+				revision = event.Kv.ModRevision
+			}
+		}
+		select {
+		case <-r.Done():
+			return
+		default:
+		}
+		logger.ERR("watch metas closed: ", revision)
+		// changes may be missed until watching again
+		actor.ResetMetaCache()
+		if !received {
+			// revision compacted or watch failed, watch from now
+			revision = 0
+			select {
+			case <-r.Done():
+				return
+			case <-time.After(time.Second):
+			}
 		}
 	}
 }
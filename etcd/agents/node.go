@@ -26,30 +26,44 @@ package agents
 import (
 	"context"
 	"encoding/json"
-	"github.com/coreos/etcd/mvcc/mvccpb"
+	"errors"
 	"github.com/mafei198/gactor/actor"
 	"github.com/mafei198/gactor/cluster"
-	"github.com/mafei198/gactor/etcd"
+	"github.com/mafei198/gactor/registry"
 	"github.com/mafei198/goslib/logger"
 	"github.com/mafei198/goslib/misc"
-	"go.etcd.io/etcd/clientv3"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 type NodeAgent struct {
-	Node *cluster.Node
+	Node     *cluster.Node
+	LeaseTTL int64 // seconds
+
+	mutex sync.Mutex
+	lease registry.LeaseID
 }
 
-func NewNodeAgent(port int) (*NodeAgent, error) {
-	localIP, err := misc.GetLocalIp()
-	if err != nil {
-		return nil, err
+const DefaultLeaseTTL = 5
+
+// host is the address advertised to other nodes, local ip if blank.
+func NewNodeAgent(role, host string, port int) (*NodeAgent, error) {
+	if host == "" {
+		localIP, err := misc.GetLocalIp()
+		if err != nil {
+			return nil, err
+		}
+		host = localIP
 	}
-	node := cluster.NewNode(cluster.RoleDefault, localIP, strconv.Itoa(port))
+	if role == "" {
+		role = cluster.RoleDefault
+	}
+	node := cluster.NewNode(role, host, strconv.Itoa(port))
 	return &NodeAgent{
-		Node: node,
+		Node:     node,
+		LeaseTTL: DefaultLeaseTTL,
 	}, nil
 }
 
@@ -68,24 +82,24 @@ func (n *NodeAgent) Start() error {
 
 func (n *NodeAgent) Watch() error {
 	nodes := &sync.Map{}
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	rsp, err := etcd.Client.Get(ctx, cluster.NodePrefix(), clientv3.WithPrefix())
+	ctx, cancel := context.WithTimeout(context.Background(), registry.RequestTimeout)
+	kvs, revision, err := registry.Current.GetPrefix(ctx, cluster.NodePrefix())
 	cancel()
 	if err != nil {
 		return err
 	}
-	for _, kv := range rsp.Kvs {
+	for _, kv := range kvs {
 		n.storeNode(nodes, kv)
 	}
 	cluster.CacheNodes = nodes
-	ch := etcd.Client.Watch(context.Background(), cluster.NodePrefix(), clientv3.WithPrefix())
-	for result := range ch {
-		for _, event := range result.Events {
+	ch := registry.Current.Watch(context.Background(), cluster.NodePrefix(), revision+1)
+	for events := range ch {
+		for _, event := range events {
 			switch event.Type {
-			case mvccpb.PUT:
+			case registry.EventPut:
 				n.storeNode(nodes, event.Kv)
-			case mvccpb.DELETE:
-				nodes.Delete(string(event.Kv.Key))
+			case registry.EventDelete:
+				nodes.Delete(strings.TrimPrefix(event.Kv.Key, cluster.NodePrefix()))
 			}
 		}
 	}
@@ -107,29 +121,33 @@ func (n *NodeAgent) retryWatchNodes() {
 	}
 }
 
-func (n *NodeAgent) storeNode(nodes *sync.Map, kv *mvccpb.KeyValue) {
+func (n *NodeAgent) storeNode(nodes *sync.Map, kv *registry.KeyValue) {
 	node := &cluster.Node{}
 	err := json.Unmarshal(kv.Value, node)
 	if err == nil {
-		nodes.Store(string(kv.Key), node)
+		nodes.Store(node.Uuid, node)
 	} else {
 		logger.ERR("unmarshal node failed: ", err, string(kv.Value))
 	}
 }
 
 func (n *NodeAgent) KeepAlive(node *cluster.Node) error {
-	lease, err := etcd.Client.Grant(context.TODO(), 5)
+	lease, err := registry.Current.Grant(context.TODO(), n.LeaseTTL)
 	if err != nil {
 		return err
 	}
+	n.mutex.Lock()
+	n.lease = lease
+	n.mutex.Unlock()
+	actor.SetNodeLease(lease)
 	go func() {
-		ch, err := etcd.Client.KeepAlive(context.TODO(), lease.ID)
+		ch, err := registry.Current.KeepAlive(context.TODO(), lease)
 		if err != nil {
 			logger.ERR("keepalive failed: ", err)
-		}
-		for ka := range ch {
-			logger.INFO("node ttl: ", ka)
-			updateNode(etcd.Client, node, lease)
+		} else {
+			for range ch {
+				_ = n.publish(node)
+			}
 		}
 		logger.ERR("keepalive channel closed!")
 		n.RetryKeepAlive(node)
@@ -148,16 +166,44 @@ func (n *NodeAgent) RetryKeepAlive(node *cluster.Node) {
 	}
 }
 
-func updateNode(cli *clientv3.Client, node *cluster.Node, lease *clientv3.LeaseGrantResponse) {
-	ccu, err := actor.GetActorAmount()
+// Mark node draining and publish it immediately, no actor will be dispatched to it.
+func (n *NodeAgent) Drain() error {
+	n.mutex.Lock()
+	n.Node.Draining = true
+	node := *n.Node
+	n.mutex.Unlock()
+	cluster.CacheNodes.Store(node.Uuid, &node)
+	return n.publish(n.Node)
+}
+
+func (n *NodeAgent) publish(node *cluster.Node) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.lease == 0 {
+		return errors.New("node lease not granted")
+	}
+	return updateNode(node, n.lease)
+}
+
+func updateNode(node *cluster.Node, lease registry.LeaseID) error {
+	load, err := actor.GetLoad()
 	if err != nil {
-		logger.ERR("get actor amount failed: ", err)
+		logger.ERR("get node load failed: ", err)
+	} else {
+		var ccu int32
+		for _, amount := range load.Actors {
+			ccu += amount
+		}
+		node.Ccu = ccu
+		node.Load = load
 	}
-	node.Ccu = ccu
 	data, err := json.Marshal(node)
 	if err != nil {
 		logger.ERR("marshal node failed: ", err, node)
+		return err
 	}
-	_, err = cli.Put(context.TODO(), node.Uuid, string(data), clientv3.WithLease(lease.ID))
-	misc.PrintMemUsage()
+	if err = registry.Current.Put(context.TODO(), cluster.NodeKey(node.Uuid), data, lease); err != nil {
+		logger.ERR("update node failed: ", err, node.Uuid)
+	}
+	return err
 }
@@ -0,0 +1,86 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package agents
+
+import (
+	"context"
+	"github.com/mafei198/gactor/registry"
+	"github.com/mafei198/goslib/logger"
+	"time"
+)
+
+// Cache of keys under prefix, load returns the revision of loaded keys
+type prefixCache struct {
+	prefix string
+	load   func() (int64, error)
+	put    func(key string)
+	del    func(key string)
+}
+
+func (c *prefixCache) start() error {
+	revision, err := c.load()
+	if err != nil {
+		return err
+	}
+	go c.watch(revision)
+	return nil
+}
+
+// stops when registry is closed
+func (c *prefixCache) watch(revision int64) {
+	r := registry.Current
+	for {
+		ch := r.Watch(context.Background(), c.prefix, revision+1)
+		for events := range ch {
+			for _, event := range events {
+				switch event.Type {
+				case registry.EventPut:
+					c.put(event.Kv.Key)
+				case registry.EventDelete:
+					c.del(event.Kv.Key)
+				}
+				revision = event.Kv.ModRevision
+			}
+		}
+		select {
+		case <-r.Done():
+			return
+		default:
+		}
+		logger.ERR("watch closed: ", c.prefix)
+		// reload since events may be compacted
+		for {
+			var err error
+			if revision, err = c.load(); err == nil {
+				break
+			}
+			logger.ERR("reload failed: ", c.prefix, err)
+			select {
+			case <-r.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}
+}
@@ -24,20 +24,58 @@ THE SOFTWARE.
 package etcd
 
 import (
+	"crypto/tls"
 	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/clientv3/namespace"
 	"time"
 )
 
+type Config struct {
+	Endpoints      []string
+	DialTimeout    time.Duration
+	RequestTimeout time.Duration
+	Username       string
+	Password       string
+	TLS            *tls.Config
+	Namespace      string // prefix prepended to every key
+}
+
 var Client *clientv3.Client
 
+// timeout of a single etcd request
+var RequestTimeout = 5 * time.Second
+
+func DefaultConfig() *Config {
+	return &Config{
+		Endpoints:      []string{"127.0.0.1:2379"},
+		DialTimeout:    5 * time.Second,
+		RequestTimeout: 5 * time.Second,
+	}
+}
+
 func Start() error {
+	return StartWithConfig(DefaultConfig())
+}
+
+func StartWithConfig(conf *Config) error {
 	cli, err := clientv3.New(clientv3.Config{
-		Endpoints:   []string{"127.0.0.1:2379"},
-		DialTimeout: 5 * time.Second,
+		Endpoints:   conf.Endpoints,
+		DialTimeout: conf.DialTimeout,
+		Username:    conf.Username,
+		Password:    conf.Password,
+		TLS:         conf.TLS,
 	})
 	if err != nil {
 		return err
 	}
+	if conf.Namespace != "" {
+		cli.KV = namespace.NewKV(cli.KV, conf.Namespace)
+		cli.Watcher = namespace.NewWatcher(cli.Watcher, conf.Namespace)
+		cli.Lease = namespace.NewLease(cli.Lease, conf.Namespace)
+	}
+	if conf.RequestTimeout > 0 {
+		RequestTimeout = conf.RequestTimeout
+	}
 	Client = cli
 	return err
 }
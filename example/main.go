@@ -37,6 +37,9 @@ func main() {
 	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
 	<-stopChan // wait for SIGINT or SIGTERM
 	logger.INFO("Shutting gactor ...")
+	if err := gactor.Drain(true); err != nil {
+		logger.ERR("drain gactor failed: ", err)
+	}
 	gactor.Stop()
 }
 
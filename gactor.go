@@ -1,23 +1,80 @@
 package gactor
 
-import "github.com/mafei198/gactor/actor"
+import (
+	"context"
+	"github.com/golang/protobuf/proto"
+	"github.com/mafei198/gactor/actor"
+	"github.com/mafei198/goslib/gen_server"
+)
 
 func Call(toActorId string, params interface{}) (interface{}, error) {
 	return actor.Call(toActorId, params)
 }
 
+func CallContext(ctx context.Context, toActorId string, params interface{}) (interface{}, error) {
+	return actor.CallContext(ctx, toActorId, params)
+}
+
 func Cast(toActorId string, params interface{}) error {
 	return actor.Cast(toActorId, params)
 }
 
-func RpcCall(toActorId string, params interface{}) (interface{}, error) {
-	return actor.RpcCall(toActorId, params)
+func RpcCall(toActorId string, params interface{}, options ...*gen_server.Option) (interface{}, error) {
+	return actor.RpcCall(toActorId, params, options...)
+}
+
+func RpcCallContext(ctx context.Context, toActorId string, params interface{}) (interface{}, error) {
+	return actor.RpcCallContext(ctx, toActorId, params)
 }
 
 func RpcCast(toActorId string, params interface{}) error {
 	return actor.RpcCast(toActorId, params)
 }
 
-func RpcAsyncCall(fromActorId, toActorId string, params interface{}, cb actor.RpcHandler) error {
-	return actor.RpcAsyncCall(fromActorId, toActorId, params, cb)
+func RpcAsyncCall(fromActorId, toActorId string, params interface{}, cb actor.RpcHandler, options ...*gen_server.Option) error {
+	return actor.RpcAsyncCall(fromActorId, toActorId, params, cb, options...)
+}
+
+func Migrate(actorId, targetNodeId string) error {
+	return actor.Migrate(actorId, targetNodeId)
+}
+
+func Monitor(watcherId, targetId string) error {
+	return actor.Monitor(watcherId, targetId)
+}
+
+func Demonitor(watcherId, targetId string) error {
+	return actor.Demonitor(watcherId, targetId)
+}
+
+func Link(actorId, peerId string) error {
+	return actor.Link(actorId, peerId)
+}
+
+func Unlink(actorId, peerId string) error {
+	return actor.Unlink(actorId, peerId)
+}
+
+func JoinGroup(group, actorId string) error {
+	return actor.JoinGroup(group, actorId)
+}
+
+func LeaveGroup(group, actorId string) error {
+	return actor.LeaveGroup(group, actorId)
+}
+
+func Broadcast(group string, msg proto.Message) error {
+	return actor.Broadcast(group, msg)
+}
+
+func Subscribe(topic, actorId string) error {
+	return actor.Subscribe(topic, actorId)
+}
+
+func Unsubscribe(topic, actorId string) error {
+	return actor.Unsubscribe(topic, actorId)
+}
+
+func Publish(topic string, msg proto.Message) error {
+	return actor.Publish(topic, msg)
 }
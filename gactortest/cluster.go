@@ -0,0 +1,313 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package gactortest boots a cluster of gactor nodes for tests of placement,
+// rpc routing and failover.
+//
+// Node state of gactor is process wide (current node id, actor and rpc
+// managers, meta cache) and gen_server names are global in goslib, so every
+// node runs in its own process: node 0 is the test process, the others are the
+// test binary started again in node mode. Behaviour of a single node is tested
+// in the actor package instead.
+// Nodes share the MemoryRegistry of the test process, each keeps its own view
+// of it through watches, and serves rpc on a localhost gRPC listener.
+//
+//	func TestMain(m *testing.M) {
+//		gactortest.Main(m, 3, func() {
+//			// register factories and messages, called in every node
+//		})
+//	}
+package gactortest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mafei198/gactor"
+	"github.com/mafei198/gactor/cluster"
+	"github.com/mafei198/gactor/registry"
+	"github.com/mafei198/goslib/logger"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	envNode     = "GACTORTEST_NODE"
+	envRegistry = "GACTORTEST_REGISTRY"
+)
+
+// Lease ttl of nodes in seconds, a killed node is removed after it
+var LeaseTTL int64 = 1
+
+// Max time of waiting nodes to join or leave
+var WaitTimeout = 10 * time.Second
+
+var (
+	errLocalNode  = errors.New("node 0 is the test process")
+	errBadIndex   = errors.New("node index out of range")
+	errNotRunning = errors.New("node not running")
+)
+
+type Cluster struct {
+	Registry *registry.MemoryRegistry
+
+	mutex sync.Mutex
+	ln    net.Listener
+	procs []*nodeProcess // nil for node 0 and stopped nodes
+}
+
+type nodeProcess struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	done  chan struct{}
+}
+
+var current *Cluster
+
+// Cluster started by Main
+func Current() *Cluster {
+	return current
+}
+
+// Role of node, Dispatch of DispatchTypeRole with it places actors on the node
+func Role(index int) string {
+	return "gactortest." + strconv.Itoa(index)
+}
+
+// Main runs tests in a cluster of n nodes, setup registers factories and
+// messages, it is called in every node before the node starts.
+func Main(m *testing.M, n int, setup func()) {
+	if index := os.Getenv(envNode); index != "" {
+		os.Exit(runNode(index, setup))
+	}
+	os.Exit(runCluster(m, n, setup))
+}
+
+func runCluster(m *testing.M, n int, setup func()) int {
+	c := &Cluster{
+		Registry: registry.NewMemoryRegistry(),
+		procs:    make([]*nodeProcess, n),
+	}
+	ln, err := serveRegistry(c.Registry)
+	if err != nil {
+		logger.ERR("gactortest serve registry failed: ", err)
+		return 1
+	}
+	c.ln = ln
+	current = c
+	setup()
+	if err := gactor.StartWithOptions(nodeOptions(0, c.Registry)); err != nil {
+		logger.ERR("gactortest start node 0 failed: ", err)
+		return 1
+	}
+	code := 1
+	if err := c.startAll(); err != nil {
+		logger.ERR("gactortest start nodes failed: ", err)
+	} else {
+		code = m.Run()
+	}
+	c.shutdown()
+	return code
+}
+
+func (c *Cluster) startAll() error {
+	for i := 1; i < len(c.procs); i++ {
+		if err := c.Start(i); err != nil {
+			return err
+		}
+	}
+	for i := range c.procs {
+		if err := c.WaitNode(i, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Nodes are stopped in reverse order, node 0 at last
+func (c *Cluster) shutdown() {
+	for i := len(c.procs) - 1; i > 0; i-- {
+		if err := c.Stop(i); err != nil && err != errNotRunning {
+			logger.ERR("gactortest stop node failed: ", i, err)
+		}
+	}
+	gactor.Stop()
+	_ = c.ln.Close()
+	_ = c.Registry.Close()
+}
+
+func (c *Cluster) Size() int {
+	return len(c.procs)
+}
+
+// Id of node seen by the test process, blank if the node is not alive
+func (c *Cluster) NodeId(index int) string {
+	var nodeId string
+	role := Role(index)
+	cluster.CacheNodes.Range(func(key, value interface{}) bool {
+		if node := value.(*cluster.Node); node.Role == role {
+			nodeId = node.Uuid
+			return false
+		}
+		return true
+	})
+	return nodeId
+}
+
+// Wait until node is seen alive, or removed if alive is false
+func (c *Cluster) WaitNode(index int, alive bool) error {
+	deadline := time.Now().Add(WaitTimeout)
+	for time.Now().Before(deadline) {
+		if (c.NodeId(index) != "") == alive {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return fmt.Errorf("gactortest wait node %d alive=%v timeout", index, alive)
+}
+
+// Start node process, again after Kill or Stop
+func (c *Cluster) Start(index int) error {
+	if index == 0 {
+		return errLocalNode
+	}
+	if index < 0 || index >= len(c.procs) {
+		return errBadIndex
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.procs[index] != nil {
+		return nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe)
+	cmd.Env = append(os.Environ(),
+		envNode+"="+strconv.Itoa(index),
+		envRegistry+"="+c.ln.Addr().String())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// node stops when stdin is closed, by Stop or exit of the test process
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	proc := &nodeProcess{cmd: cmd, stdin: stdin, done: make(chan struct{})}
+	go func() {
+		_ = cmd.Wait()
+		close(proc.done)
+	}()
+	c.procs[index] = proc
+	return nil
+}
+
+// Kill node process like a crash, it leaves the cluster after LeaseTTL
+func (c *Cluster) Kill(index int) error {
+	proc, err := c.takeProcess(index)
+	if err != nil {
+		return err
+	}
+	if err = proc.cmd.Process.Kill(); err != nil {
+		return err
+	}
+	<-proc.done
+	return nil
+}
+
+// Stop node gracefully, gactor.Stop runs before the process exits
+func (c *Cluster) Stop(index int) error {
+	proc, err := c.takeProcess(index)
+	if err != nil {
+		return err
+	}
+	_ = proc.stdin.Close()
+	select {
+	case <-proc.done:
+		return nil
+	case <-time.After(WaitTimeout):
+		_ = proc.cmd.Process.Kill()
+		<-proc.done
+		return fmt.Errorf("gactortest stop node %d timeout", index)
+	}
+}
+
+func (c *Cluster) takeProcess(index int) (*nodeProcess, error) {
+	if index == 0 {
+		return nil, errLocalNode
+	}
+	if index < 0 || index >= len(c.procs) {
+		return nil, errBadIndex
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	proc := c.procs[index]
+	if proc == nil {
+		return nil, errNotRunning
+	}
+	c.procs[index] = nil
+	return proc, nil
+}
+
+func runNode(indexStr string, setup func()) int {
+	index, err := strconv.Atoi(indexStr)
+	if err != nil {
+		logger.ERR("gactortest bad node index: ", indexStr)
+		return 1
+	}
+	r, err := dialRegistry(os.Getenv(envRegistry))
+	if err != nil {
+		logger.ERR("gactortest dial registry failed: ", err)
+		return 1
+	}
+	setup()
+	if err = gactor.StartWithOptions(nodeOptions(index, r)); err != nil {
+		logger.ERR("gactortest start node failed: ", index, err)
+		return 1
+	}
+	_, _ = io.Copy(ioutil.Discard, os.Stdin)
+	gactor.Stop()
+	_ = r.Close()
+	return 0
+}
+
+func nodeOptions(index int, r registry.Registry) *gactor.Options {
+	opts := gactor.DefaultOptions()
+	opts.Registry = r
+	opts.RpcListenHost = "127.0.0.1"
+	opts.AdvertiseHost = "127.0.0.1"
+	opts.Role = Role(index)
+	opts.LeaseTTL = LeaseTTL
+	return opts
+}
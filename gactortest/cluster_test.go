@@ -0,0 +1,221 @@
+package gactortest
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/mafei198/gactor"
+	"github.com/mafei198/gactor/actor"
+	"github.com/mafei198/gactor/api"
+	"github.com/mafei198/gactor/cluster"
+	"github.com/mafei198/goslib/pbmsg"
+	"testing"
+	"time"
+)
+
+type echoActor struct{ count int32 }
+
+func (a *echoActor) OnStart(server *actor.Server) error { return nil }
+func (a *echoActor) OnStop(reason string) error         { return nil }
+
+func (a *echoActor) MarshalState() ([]byte, error) { return json.Marshal(a.count) }
+func (a *echoActor) UnmarshalState(data []byte) error {
+	return json.Unmarshal(data, &a.count)
+}
+
+var echoFactory *actor.Factory
+
+// echo actor replies id of the node hosting it, and counts Int32Value casts
+func setup() {
+	pbmsg.Register(func() proto.Message { return &wrappers.StringValue{} })
+	pbmsg.Register(func() proto.Message { return &wrappers.Int32Value{} })
+	pbmsg.Register(func() proto.Message { return &wrappers.Int64Value{} })
+	echoFactory = actor.NewFactory(func() actor.Behavior { return &echoActor{} })
+	echoFactory.Register(&wrappers.StringValue{}, func(req *api.Request) proto.Message {
+		return &wrappers.StringValue{Value: cluster.GetCurrentNodeId()}
+	})
+	echoFactory.Register(&wrappers.Int32Value{}, func(req *api.Request) proto.Message {
+		a := req.Ctx.(*echoActor)
+		a.count += req.Params.(*wrappers.Int32Value).Value
+		return nil
+	})
+	echoFactory.Register(&wrappers.Int64Value{}, func(req *api.Request) proto.Message {
+		return &wrappers.Int64Value{Value: int64(req.Ctx.(*echoActor).count)}
+	})
+}
+
+// create echo actor placed on node of index
+func createEcho(t *testing.T, actorId string, index int) {
+	dispatch := actor.NewDispatch(actor.DispatchTypeRole, Role(index))
+	if _, err := actor.AddMeta(echoFactory.Category, actorId, dispatch); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMain(m *testing.M) {
+	Main(m, 3, setup)
+}
+
+func echo(t *testing.T, actorId string) string {
+	rsp, err := gactor.RpcCall(actorId, &wrappers.StringValue{})
+	if err != nil {
+		t.Fatal("rpc call failed: ", err)
+	}
+	msg, err := pbmsg.Decode(rsp.(*actor.RpcRspParams).Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg.(*wrappers.StringValue).Value
+}
+
+func count(t *testing.T, actorId string) int64 {
+	rsp, err := gactor.RpcCall(actorId, &wrappers.Int64Value{})
+	if err != nil {
+		t.Fatal("rpc call failed: ", err)
+	}
+	msg, err := pbmsg.Decode(rsp.(*actor.RpcRspParams).Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg.(*wrappers.Int64Value).Value
+}
+
+func TestCrossNodeCall(t *testing.T) {
+	c := Current()
+	actorId := actor.GenMetaId()
+	createEcho(t, actorId, 1)
+	if nodeId := echo(t, actorId); nodeId != c.NodeId(1) {
+		t.Fatal("served by ", nodeId, " expect ", c.NodeId(1))
+	}
+}
+
+func TestNodeDeath(t *testing.T) {
+	c := Current()
+	actorId := actor.GenMetaId()
+	createEcho(t, actorId, 2)
+	dead := echo(t, actorId)
+	if dead != c.NodeId(2) {
+		t.Fatal("served by ", dead, " expect ", c.NodeId(2))
+	}
+	if err := c.Kill(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WaitNode(2, false); err != nil {
+		t.Fatal(err)
+	}
+	// re-dispatched to an alive node
+	if nodeId := echo(t, actorId); nodeId == dead || nodeId == "" {
+		t.Fatal("served by dead node: ", nodeId)
+	}
+	if err := c.Start(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WaitNode(2, true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMetaWatch(t *testing.T) {
+	c := Current()
+	actorId := actor.GenMetaId()
+	createEcho(t, actorId, 1)
+	meta, err := actor.GetMeta(actorId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// reassigned elsewhere, written to registry without touching local cache
+	ctx := context.Background()
+	kv, err := c.Registry.Get(ctx, cluster.MetaKey(actorId))
+	if err != nil || kv == nil {
+		t.Fatal("get meta failed: ", err)
+	}
+	updated := *meta
+	updated.NodeId = c.NodeId(2)
+	data, _ := json.Marshal(&updated)
+	if _, ok, err := c.Registry.CompareAndSwap(ctx, kv.Key, kv.ModRevision, data); !ok || err != nil {
+		t.Fatal("update meta failed: ", err)
+	}
+	deadline := time.Now().Add(WaitTimeout)
+	for {
+		meta, err := actor.GetMeta(actorId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.NodeId == updated.NodeId {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("meta change not seen")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if nodeId := echo(t, actorId); nodeId != updated.NodeId {
+		t.Fatal("served by ", nodeId, " expect ", updated.NodeId)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	c := Current()
+	actorId := actor.GenMetaId()
+	createEcho(t, actorId, 0)
+	server, err := actor.GetActor(actorId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = actor.Cast(actorId, &wrappers.Int32Value{Value: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err = actor.Migrate(actorId, c.NodeId(1)); err != nil {
+		t.Fatal("migrate failed: ", err)
+	}
+	if nodeId := echo(t, actorId); nodeId != c.NodeId(1) {
+		t.Fatal("served by ", nodeId, " expect ", c.NodeId(1))
+	}
+	// sent to the old instance in grace period, forwarded to target node
+	if err = server.Cast(&wrappers.Int32Value{Value: 1}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(WaitTimeout)
+	for count(t, actorId) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("cast in grace period lost")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Graceful stop expires metas of its actors before the node leaves, they're
+// dispatched again without waiting for the node lease
+func TestShutdown(t *testing.T) {
+	c := Current()
+	actorId := actor.GenMetaId()
+	createEcho(t, actorId, 2)
+	stopped := echo(t, actorId)
+	if stopped != c.NodeId(2) {
+		t.Fatal("served by ", stopped, " expect ", c.NodeId(2))
+	}
+	if err := c.Stop(2); err != nil {
+		t.Fatal(err)
+	}
+	kv, err := c.Registry.Get(context.Background(), cluster.MetaKey(actorId))
+	if err != nil || kv == nil {
+		t.Fatal("get meta failed: ", err)
+	}
+	meta := &actor.Meta{}
+	if err = json.Unmarshal(kv.Value, meta); err != nil || meta.NodeId != "" {
+		t.Fatal("meta not expired before node stopped: ", meta.NodeId, err)
+	}
+	if err = c.WaitNode(2, false); err != nil {
+		t.Fatal(err)
+	}
+	if nodeId := echo(t, actorId); nodeId == stopped || nodeId == "" {
+		t.Fatal("served by stopped node: ", nodeId)
+	}
+	if err = c.Start(2); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.WaitNode(2, true); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,302 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package gactortest
+
+import (
+	"context"
+	"github.com/mafei198/gactor/registry"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+)
+
+// Node processes share the MemoryRegistry of the test process through net/rpc,
+// the types below are exported only because net/rpc requires it.
+
+type RegistryArgs struct {
+	Key      string
+	Value    []byte
+	Lease    registry.LeaseID
+	Revision int64
+	TTL      int64
+}
+
+type RegistryReply struct {
+	Kv       *registry.KeyValue
+	Kvs      []*registry.KeyValue
+	Revision int64
+	Ok       bool
+	Lease    registry.LeaseID
+}
+
+type WatchArgs struct {
+	Id       int64
+	Prefix   string
+	Revision int64
+}
+
+type WatchReply struct {
+	Id     int64
+	Events []*registry.Event
+	Closed bool
+}
+
+// max time a watch poll is held
+const watchPoll = time.Second
+
+// interval of renewing leases of node processes
+const renewInterval = 300 * time.Millisecond
+
+type RegistryService struct {
+	r         *registry.MemoryRegistry
+	mutex     sync.Mutex
+	nextWatch int64
+	watches   map[int64]*serviceWatch
+}
+
+type serviceWatch struct {
+	ch     <-chan []*registry.Event
+	cancel context.CancelFunc
+}
+
+func serveRegistry(r *registry.MemoryRegistry) (net.Listener, error) {
+	server := rpc.NewServer()
+	service := &RegistryService{r: r, watches: map[int64]*serviceWatch{}}
+	if err := server.RegisterName("Registry", service); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	go server.Accept(ln)
+	return ln, nil
+}
+
+func (s *RegistryService) Get(args *RegistryArgs, reply *RegistryReply) (err error) {
+	reply.Kv, err = s.r.Get(context.Background(), args.Key)
+	return
+}
+
+func (s *RegistryService) GetPrefix(args *RegistryArgs, reply *RegistryReply) (err error) {
+	reply.Kvs, reply.Revision, err = s.r.GetPrefix(context.Background(), args.Key)
+	return
+}
+
+func (s *RegistryService) Put(args *RegistryArgs, reply *RegistryReply) error {
+	return s.r.Put(context.Background(), args.Key, args.Value, args.Lease)
+}
+
+func (s *RegistryService) Delete(args *RegistryArgs, reply *RegistryReply) error {
+	return s.r.Delete(context.Background(), args.Key)
+}
+
+func (s *RegistryService) CompareAndSwap(args *RegistryArgs, reply *RegistryReply) (err error) {
+	reply.Kv, reply.Ok, err = s.r.CompareAndSwap(context.Background(), args.Key, args.Revision, args.Value)
+	return
+}
+
+func (s *RegistryService) Grant(args *RegistryArgs, reply *RegistryReply) (err error) {
+	reply.Lease, err = s.r.Grant(context.Background(), args.TTL)
+	return
+}
+
+func (s *RegistryService) KeepAliveOnce(args *RegistryArgs, reply *RegistryReply) error {
+	return s.r.KeepAliveOnce(context.Background(), args.Lease)
+}
+
+func (s *RegistryService) Watch(args *WatchArgs, reply *WatchReply) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.nextWatch++
+	s.watches[s.nextWatch] = &serviceWatch{
+		ch:     s.r.Watch(ctx, args.Prefix, args.Revision),
+		cancel: cancel,
+	}
+	reply.Id = s.nextWatch
+	return nil
+}
+
+// Events of watch, or nothing if no event within watchPoll
+func (s *RegistryService) Next(args *WatchArgs, reply *WatchReply) error {
+	s.mutex.Lock()
+	w, ok := s.watches[args.Id]
+	s.mutex.Unlock()
+	if !ok {
+		reply.Closed = true
+		return nil
+	}
+	select {
+	case events, ok := <-w.ch:
+		if !ok {
+			s.cancelWatch(args.Id)
+			reply.Closed = true
+		}
+		reply.Events = events
+	case <-time.After(watchPoll):
+	}
+	return nil
+}
+
+func (s *RegistryService) Cancel(args *WatchArgs, reply *WatchReply) error {
+	s.cancelWatch(args.Id)
+	return nil
+}
+
+func (s *RegistryService) cancelWatch(id int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if w, ok := s.watches[id]; ok {
+		w.cancel()
+		delete(s.watches, id)
+	}
+}
+
+// Registry of node processes, backed by RegistryService of the test process
+type remoteRegistry struct {
+	client *rpc.Client
+	done   chan struct{}
+	once   sync.Once
+}
+
+func dialRegistry(addr string) (*remoteRegistry, error) {
+	client, err := rpc.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteRegistry{client: client, done: make(chan struct{})}, nil
+}
+
+func (r *remoteRegistry) call(ctx context.Context, method string, args, reply interface{}) error {
+	call := r.client.Go("Registry."+method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		if call.Error != nil && call.Error.Error() == registry.ErrLeaseNotFound.Error() {
+			return registry.ErrLeaseNotFound
+		}
+		return call.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *remoteRegistry) Get(ctx context.Context, key string) (*registry.KeyValue, error) {
+	reply := &RegistryReply{}
+	err := r.call(ctx, "Get", &RegistryArgs{Key: key}, reply)
+	return reply.Kv, err
+}
+
+func (r *remoteRegistry) GetPrefix(ctx context.Context, prefix string) ([]*registry.KeyValue, int64, error) {
+	reply := &RegistryReply{}
+	err := r.call(ctx, "GetPrefix", &RegistryArgs{Key: prefix}, reply)
+	return reply.Kvs, reply.Revision, err
+}
+
+func (r *remoteRegistry) Put(ctx context.Context, key string, value []byte, lease registry.LeaseID) error {
+	return r.call(ctx, "Put", &RegistryArgs{Key: key, Value: value, Lease: lease}, &RegistryReply{})
+}
+
+func (r *remoteRegistry) Delete(ctx context.Context, key string) error {
+	return r.call(ctx, "Delete", &RegistryArgs{Key: key}, &RegistryReply{})
+}
+
+func (r *remoteRegistry) CompareAndSwap(ctx context.Context, key string, revision int64, value []byte) (*registry.KeyValue, bool, error) {
+	reply := &RegistryReply{}
+	err := r.call(ctx, "CompareAndSwap", &RegistryArgs{Key: key, Revision: revision, Value: value}, reply)
+	return reply.Kv, reply.Ok, err
+}
+
+func (r *remoteRegistry) Grant(ctx context.Context, ttl int64) (registry.LeaseID, error) {
+	reply := &RegistryReply{}
+	err := r.call(ctx, "Grant", &RegistryArgs{TTL: ttl}, reply)
+	return reply.Lease, err
+}
+
+func (r *remoteRegistry) KeepAlive(ctx context.Context, lease registry.LeaseID) (<-chan struct{}, error) {
+	if err := r.call(ctx, "KeepAliveOnce", &RegistryArgs{Lease: lease}, &RegistryReply{}); err != nil {
+		return nil, err
+	}
+	ticks := make(chan struct{}, 1)
+	go func() {
+		defer close(ticks)
+		ticker := time.NewTicker(renewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case ticks <- struct{}{}:
+			default:
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+			if err := r.call(ctx, "KeepAliveOnce", &RegistryArgs{Lease: lease}, &RegistryReply{}); err != nil {
+				return
+			}
+		}
+	}()
+	return ticks, nil
+}
+
+func (r *remoteRegistry) Watch(ctx context.Context, prefix string, revision int64) <-chan []*registry.Event {
+	out := make(chan []*registry.Event)
+	go func() {
+		defer close(out)
+		reply := &WatchReply{}
+		if err := r.call(ctx, "Watch", &WatchArgs{Prefix: prefix, Revision: revision}, reply); err != nil {
+			return
+		}
+		id := reply.Id
+		defer func() {
+			_ = r.client.Call("Registry.Cancel", &WatchArgs{Id: id}, &WatchReply{})
+		}()
+		for {
+			reply := &WatchReply{}
+			if err := r.call(ctx, "Next", &WatchArgs{Id: id}, reply); err != nil || reply.Closed {
+				return
+			}
+			if len(reply.Events) == 0 {
+				continue
+			}
+			select {
+			case out <- reply.Events:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (r *remoteRegistry) Done() <-chan struct{} {
+	return r.done
+}
+
+func (r *remoteRegistry) Close() error {
+	r.once.Do(func() { close(r.done) })
+	return r.client.Close()
+}
@@ -0,0 +1,237 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// One file per actor under Dir, record: seq(8) | length(4) | data | crc32(4).
+// A torn record at the tail, left by a crash while appending, is truncated
+// on replay. A bad record followed by others is ErrCorrupted, the file is
+// kept as it is for repair.
+type FileJournal struct {
+	Dir  string
+	Sync bool // fsync after every append
+
+	mutex sync.Mutex
+}
+
+const headerSize = 12
+
+// Max data size of a record, a larger length read from file is corruption
+var MaxRecordSize = 16 << 20
+
+var ErrCorrupted = errors.New("journal record corrupted")
+
+var (
+	errTorn           = errors.New("journal record torn")
+	errRecordTooLarge = errors.New("journal record too large")
+)
+
+func NewFileJournal(dir string, sync bool) (*FileJournal, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileJournal{Dir: dir, Sync: sync}, nil
+}
+
+// Events are appended all or none, a failed write is truncated
+func (j *FileJournal) Append(actorId string, events ...*Event) error {
+	buf := &bytes.Buffer{}
+	for _, event := range events {
+		if err := writeRecord(buf, event); err != nil {
+			return err
+		}
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	file, err := os.OpenFile(j.path(actorId), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err == nil {
+		if _, err = file.Write(buf.Bytes()); err == nil && j.Sync {
+			err = file.Sync()
+		}
+		if err != nil {
+			if truncErr := file.Truncate(info.Size()); truncErr != nil {
+				err = truncErr
+			}
+		}
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (j *FileJournal) Replay(actorId string, fromSeq uint64, handler func(event *Event) error) error {
+	j.mutex.Lock()
+	events, size, torn, err := j.read(actorId)
+	if err == nil && torn {
+		err = os.Truncate(j.path(actorId), size)
+	}
+	j.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if event.Seq <= fromSeq {
+			continue
+		}
+		if err := handler(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewrite journal with events after toSeq
+func (j *FileJournal) Compact(actorId string, toSeq uint64) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	events, _, _, err := j.read(actorId)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(j.Dir, ".journal-")
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmp)
+	for _, event := range events {
+		if event.Seq > toSeq {
+			if err = writeRecord(writer, event); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), j.path(actorId))
+}
+
+// return events, size of valid records and whether tail is torn
+func (j *FileJournal) read(actorId string) (events []*Event, size int64, torn bool, err error) {
+	file, err := os.Open(j.path(actorId))
+	if os.IsNotExist(err) {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return
+	}
+	reader := bufio.NewReader(file)
+	for {
+		event, err := readRecord(reader, info.Size()-size)
+		if err == io.EOF {
+			return events, size, false, nil
+		}
+		if err == io.ErrUnexpectedEOF || err == errTorn {
+			return events, size, true, nil
+		}
+		if err != nil {
+			return nil, 0, false, err
+		}
+		events = append(events, event)
+		size += int64(headerSize + len(event.Data) + 4)
+	}
+}
+
+func writeRecord(writer io.Writer, event *Event) error {
+	if len(event.Data) > MaxRecordSize {
+		return errRecordTooLarge
+	}
+	record := make([]byte, headerSize+len(event.Data)+4)
+	binary.BigEndian.PutUint64(record[0:8], event.Seq)
+	binary.BigEndian.PutUint32(record[8:12], uint32(len(event.Data)))
+	copy(record[headerSize:], event.Data)
+	sum := crc32.ChecksumIEEE(record[:headerSize+len(event.Data)])
+	binary.BigEndian.PutUint32(record[headerSize+len(event.Data):], sum)
+	_, err := writer.Write(record)
+	return err
+}
+
+// Length is checked before allocating, remain is the file size left to read.
+// A bad record is torn only if it's the last one in file.
+func readRecord(reader io.Reader, remain int64) (*Event, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	length := int64(binary.BigEndian.Uint32(header[8:12]))
+	if headerSize+length+4 > remain {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if length > int64(MaxRecordSize) {
+		return nil, ErrCorrupted
+	}
+	body := make([]byte, length+4)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, err
+	}
+	data := body[:len(body)-4]
+	sum := crc32.Update(crc32.ChecksumIEEE(header), crc32.IEEETable, data)
+	if sum != binary.BigEndian.Uint32(body[len(body)-4:]) {
+		if headerSize+length+4 == remain {
+			return nil, errTorn
+		}
+		return nil, ErrCorrupted
+	}
+	return &Event{
+		Seq:  binary.BigEndian.Uint64(header[0:8]),
+		Data: data,
+	}, nil
+}
+
+func (j *FileJournal) path(actorId string) string {
+	return filepath.Join(j.Dir, url.PathEscape(actorId)+".journal")
+}
@@ -0,0 +1,148 @@
+package journal
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestFileJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	j, err := NewFileJournal(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actorId := "Actor:test"
+	for seq := uint64(1); seq <= 5; seq++ {
+		if err := j.Append(actorId, &Event{Seq: seq, Data: []byte{byte(seq)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := j.Compact(actorId, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// torn record left by crash
+	file, _ := os.OpenFile(j.path(actorId), os.O_WRONLY|os.O_APPEND, 0644)
+	_, _ = file.Write([]byte{0, 0, 0})
+	_ = file.Close()
+
+	seqs := replaySeqs(t, j, actorId, 3)
+	if len(seqs) != 2 || seqs[0] != 4 || seqs[1] != 5 {
+		t.Fatalf("replay after seq 3: %v", seqs)
+	}
+	if err := j.Append(actorId, &Event{Seq: 6, Data: []byte{6}}); err != nil {
+		t.Fatal(err)
+	}
+	seqs = replaySeqs(t, j, actorId, 0)
+	if len(seqs) != 4 || seqs[0] != 3 || seqs[3] != 6 {
+		t.Fatalf("replay all: %v", seqs)
+	}
+}
+
+func replaySeqs(t *testing.T, j *FileJournal, actorId string, fromSeq uint64) []uint64 {
+	seqs := make([]uint64, 0)
+	err := j.Replay(actorId, fromSeq, func(event *Event) error {
+		if event.Data[0] != byte(event.Seq) {
+			t.Fatalf("event %d data: %v", event.Seq, event.Data)
+		}
+		seqs = append(seqs, event.Seq)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seqs
+}
+
+func TestFileJournalBadLength(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	j, err := NewFileJournal(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actorId := "Actor:test"
+	if err := j.Append(actorId, &Event{Seq: 1, Data: []byte{1}}); err != nil {
+		t.Fatal(err)
+	}
+	// header of a record longer than the file, and of one over MaxRecordSize
+	for _, length := range []uint32{100, 0xffffffff} {
+		header := make([]byte, headerSize)
+		binary.BigEndian.PutUint64(header[0:8], 2)
+		binary.BigEndian.PutUint32(header[8:12], length)
+		file, _ := os.OpenFile(j.path(actorId), os.O_WRONLY|os.O_APPEND, 0644)
+		_, _ = file.Write(header)
+		_ = file.Close()
+
+		seqs := replaySeqs(t, j, actorId, 0)
+		if len(seqs) != 1 || seqs[0] != 1 {
+			t.Fatalf("replay after bad length %d: %v", length, seqs)
+		}
+	}
+}
+
+func TestFileJournalCorrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	j, err := NewFileJournal(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actorId := "Actor:test"
+	for seq := uint64(1); seq <= 5; seq++ {
+		if err := j.Append(actorId, &Event{Seq: seq, Data: []byte{byte(seq)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recordSize := int64(headerSize + 1 + 4)
+	flip := func(offset int64) {
+		file, _ := os.OpenFile(j.path(actorId), os.O_RDWR, 0644)
+		b := make([]byte, 1)
+		_, _ = file.ReadAt(b, offset)
+		b[0] ^= 0xff
+		_, _ = file.WriteAt(b, offset)
+		_ = file.Close()
+	}
+
+	// bad record followed by others is not truncated
+	flip(recordSize + headerSize)
+	err = j.Replay(actorId, 0, func(event *Event) error { return nil })
+	if err != ErrCorrupted {
+		t.Fatal("replay corrupted journal: ", err)
+	}
+	if info, _ := os.Stat(j.path(actorId)); info.Size() != 5*recordSize {
+		t.Fatal("corrupted journal truncated: ", info.Size())
+	}
+	flip(recordSize + headerSize)
+
+	// bad last record is a torn tail
+	flip(4*recordSize + headerSize)
+	seqs := replaySeqs(t, j, actorId, 0)
+	if len(seqs) != 4 || seqs[3] != 4 {
+		t.Fatalf("replay after torn tail: %v", seqs)
+	}
+
+	// batch is appended all or none
+	size := MaxRecordSize
+	MaxRecordSize = 4
+	defer func() { MaxRecordSize = size }()
+	err = j.Append(actorId, &Event{Seq: 5, Data: []byte{5}}, &Event{Seq: 6, Data: make([]byte, 5)})
+	if err != errRecordTooLarge {
+		t.Fatal("append too large record: ", err)
+	}
+	if seqs = replaySeqs(t, j, actorId, 0); len(seqs) != 4 {
+		t.Fatalf("replay after failed append: %v", seqs)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package gactor
+
+import (
+	"crypto/tls"
+	"github.com/mafei198/gactor/cluster"
+	"github.com/mafei198/gactor/etcd"
+	"github.com/mafei198/gactor/etcd/agents"
+	"github.com/mafei198/gactor/registry"
+	"github.com/mafei198/gactor/storage"
+	"time"
+)
+
+type Options struct {
+	// etcd
+	EtcdEndpoints   []string
+	EtcdDialTimeout time.Duration
+	EtcdUsername    string
+	EtcdPassword    string
+	EtcdTLS         *tls.Config
+
+	// Rpc server listens on RpcListenHost:RpcPort, if RpcPortMax > RpcPort
+	// the first free port in [RpcPort, RpcPortMax] is used, RpcPort 0 for random port.
+	RpcListenHost string
+	RpcPort       int
+	RpcPortMax    int
+
+	// Address published to other nodes, for NAT or containers.
+	// AdvertiseHost defaults to local ip, AdvertisePort to the listening port.
+	AdvertiseHost string
+	AdvertisePort int
+
+	Role           string        // node role, used by DispatchTypeRole
+	Weight         int32         // node weight, used by weighted placement strategies
+	Namespace      string        // prefix of all etcd keys, isolates clusters sharing one etcd
+	ClusterName    string        // keys are laid out under /<ClusterName>/<schema version>/
+	RequestTimeout time.Duration // timeout of etcd requests
+	LeaseTTL       int64         // node lease ttl in seconds
+
+	Storage storage.Storage // default storage of Persistent actors
+
+	MetaCacheTTL  time.Duration // actor.MetaCacheTTL if zero
+	MetaCacheSize int           // actor.MetaCacheSize if zero
+
+	// Cluster registry, etcd is connected with the etcd options above if nil,
+	// registry.NewMemoryRegistry() runs a single node without etcd.
+	Registry registry.Registry
+}
+
+func DefaultOptions() *Options {
+	return &Options{
+		EtcdEndpoints:   []string{"127.0.0.1:2379"},
+		EtcdDialTimeout: 5 * time.Second,
+		Role:            cluster.RoleDefault,
+		ClusterName:     cluster.DefaultClusterName,
+		RequestTimeout:  5 * time.Second,
+		LeaseTTL:        agents.DefaultLeaseTTL,
+	}
+}
+
+// fill zero values with defaults
+func (o *Options) normalize() *Options {
+	def := DefaultOptions()
+	opts := *o
+	if len(opts.EtcdEndpoints) == 0 {
+		opts.EtcdEndpoints = def.EtcdEndpoints
+	}
+	if opts.EtcdDialTimeout <= 0 {
+		opts.EtcdDialTimeout = def.EtcdDialTimeout
+	}
+	if opts.Role == "" {
+		opts.Role = def.Role
+	}
+	if opts.ClusterName == "" {
+		opts.ClusterName = def.ClusterName
+	}
+	if opts.RequestTimeout <= 0 {
+		opts.RequestTimeout = def.RequestTimeout
+	}
+	if opts.LeaseTTL <= 0 {
+		opts.LeaseTTL = def.LeaseTTL
+	}
+	return &opts
+}
+
+func (o *Options) etcdConfig() *etcd.Config {
+	return &etcd.Config{
+		Endpoints:      o.EtcdEndpoints,
+		DialTimeout:    o.EtcdDialTimeout,
+		RequestTimeout: o.RequestTimeout,
+		Username:       o.EtcdUsername,
+		Password:       o.EtcdPassword,
+		TLS:            o.EtcdTLS,
+		Namespace:      o.Namespace,
+	}
+}
@@ -0,0 +1,163 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package registry
+
+import (
+	"context"
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	"go.etcd.io/etcd/clientv3"
+)
+
+type EtcdRegistry struct {
+	Client *clientv3.Client
+}
+
+func NewEtcdRegistry(cli *clientv3.Client) *EtcdRegistry {
+	return &EtcdRegistry{Client: cli}
+}
+
+func (r *EtcdRegistry) Get(ctx context.Context, key string) (*KeyValue, error) {
+	rsp, err := r.Client.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(rsp.Kvs) == 0 {
+		return nil, nil
+	}
+	return fromEtcd(rsp.Kvs[0]), nil
+}
+
+func (r *EtcdRegistry) GetPrefix(ctx context.Context, prefix string) ([]*KeyValue, int64, error) {
+	rsp, err := r.Client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, 0, err
+	}
+	kvs := make([]*KeyValue, 0, len(rsp.Kvs))
+	for _, kv := range rsp.Kvs {
+		kvs = append(kvs, fromEtcd(kv))
+	}
+	return kvs, rsp.Header.Revision, nil
+}
+
+func (r *EtcdRegistry) Put(ctx context.Context, key string, value []byte, lease LeaseID) error {
+	var err error
+	if lease != 0 {
+		_, err = r.Client.Put(ctx, key, string(value), clientv3.WithLease(clientv3.LeaseID(lease)))
+	} else {
+		_, err = r.Client.Put(ctx, key, string(value))
+	}
+	return err
+}
+
+func (r *EtcdRegistry) Delete(ctx context.Context, key string) error {
+	_, err := r.Client.Delete(ctx, key)
+	return err
+}
+
+func (r *EtcdRegistry) CompareAndSwap(ctx context.Context, key string, revision int64, value []byte) (*KeyValue, bool, error) {
+	txn := r.Client.Txn(ctx)
+	cmp := clientv3.Compare(clientv3.ModRevision(key), "=", revision)
+	txn.If(cmp).Then(clientv3.OpPut(key, string(value))).Else(clientv3.OpGet(key))
+	rsp, err := txn.Commit()
+	if err != nil {
+		return nil, false, err
+	}
+	if rsp.Succeeded {
+		return &KeyValue{Key: key, Value: value, ModRevision: rsp.Header.Revision}, true, nil
+	}
+	kvs := rsp.Responses[0].GetResponseRange().Kvs
+	if len(kvs) == 0 {
+		return nil, false, nil
+	}
+	return fromEtcd(kvs[0]), false, nil
+}
+
+func (r *EtcdRegistry) Grant(ctx context.Context, ttl int64) (LeaseID, error) {
+	rsp, err := r.Client.Grant(ctx, ttl)
+	if err != nil {
+		return 0, err
+	}
+	return LeaseID(rsp.ID), nil
+}
+
+func (r *EtcdRegistry) KeepAlive(ctx context.Context, lease LeaseID) (<-chan struct{}, error) {
+	ch, err := r.Client.KeepAlive(ctx, clientv3.LeaseID(lease))
+	if err != nil {
+		return nil, err
+	}
+	ticks := make(chan struct{}, 1)
+	go func() {
+		defer close(ticks)
+		for range ch {
+			select {
+			case ticks <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return ticks, nil
+}
+
+func (r *EtcdRegistry) Watch(ctx context.Context, prefix string, revision int64) <-chan []*Event {
+	opts := []clientv3.OpOption{clientv3.WithPrefix()}
+	if revision > 0 {
+		opts = append(opts, clientv3.WithRev(revision))
+	}
+	ch := r.Client.Watch(ctx, prefix, opts...)
+	out := make(chan []*Event)
+	go func() {
+		defer close(out)
+		for result := range ch {
+			if result.Err() != nil {
+				return
+			}
+			events := make([]*Event, 0, len(result.Events))
+			for _, event := range result.Events {
+				e := &Event{Type: EventPut, Kv: fromEtcd(event.Kv)}
+				if event.Type == mvccpb.DELETE {
+					e.Type = EventDelete
+				}
+				events = append(events, e)
+			}
+			select {
+			case out <- events:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (r *EtcdRegistry) Done() <-chan struct{} {
+	return r.Client.Ctx().Done()
+}
+
+func (r *EtcdRegistry) Close() error {
+	return r.Client.Close()
+}
+
+func fromEtcd(kv *mvccpb.KeyValue) *KeyValue {
+	return &KeyValue{Key: string(kv.Key), Value: kv.Value, ModRevision: kv.ModRevision}
+}
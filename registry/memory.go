@@ -0,0 +1,364 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package registry
+
+import (
+	"context"
+	"github.com/mafei198/gactor/clock"
+	"strings"
+	"sync"
+	"time"
+)
+
+// events kept for watching from a past revision
+const memoryHistory = 1024
+
+// In-process registry, for single node deployments and tests
+type MemoryRegistry struct {
+	mutex     sync.Mutex
+	revision  int64
+	kvs       map[string]*memoryEntry
+	leases    map[LeaseID]*memoryLease
+	nextLease LeaseID
+	history   []*Event
+	watchers  map[*memoryWatcher]bool
+	done      chan struct{}
+}
+
+type memoryEntry struct {
+	kv    *KeyValue
+	lease LeaseID
+}
+
+type memoryLease struct {
+	ttl      time.Duration
+	expireAt time.Time
+	keys     map[string]bool
+}
+
+func NewMemoryRegistry() *MemoryRegistry {
+	r := &MemoryRegistry{
+		kvs:      map[string]*memoryEntry{},
+		leases:   map[LeaseID]*memoryLease{},
+		watchers: map[*memoryWatcher]bool{},
+		done:     make(chan struct{}),
+	}
+	go r.expireLoop()
+	return r
+}
+
+func (r *MemoryRegistry) Get(ctx context.Context, key string) (*KeyValue, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if entry, ok := r.kvs[key]; ok {
+		return copyKv(entry.kv), nil
+	}
+	return nil, nil
+}
+
+func (r *MemoryRegistry) GetPrefix(ctx context.Context, prefix string) ([]*KeyValue, int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	kvs := make([]*KeyValue, 0)
+	for key, entry := range r.kvs {
+		if strings.HasPrefix(key, prefix) {
+			kvs = append(kvs, copyKv(entry.kv))
+		}
+	}
+	return kvs, r.revision, nil
+}
+
+func (r *MemoryRegistry) Put(ctx context.Context, key string, value []byte, lease LeaseID) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if lease != 0 {
+		if _, ok := r.leases[lease]; !ok {
+			return ErrLeaseNotFound
+		}
+	}
+	r.put(key, value, lease)
+	return nil
+}
+
+func (r *MemoryRegistry) Delete(ctx context.Context, key string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.delete(key)
+	return nil
+}
+
+func (r *MemoryRegistry) CompareAndSwap(ctx context.Context, key string, revision int64, value []byte) (*KeyValue, bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var current int64
+	entry, ok := r.kvs[key]
+	if ok {
+		current = entry.kv.ModRevision
+	}
+	if current != revision {
+		if !ok {
+			return nil, false, nil
+		}
+		return copyKv(entry.kv), false, nil
+	}
+	return copyKv(r.put(key, value, 0)), true, nil
+}
+
+func (r *MemoryRegistry) Grant(ctx context.Context, ttl int64) (LeaseID, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.nextLease++
+	duration := time.Duration(ttl) * time.Second
+	r.leases[r.nextLease] = &memoryLease{
+		ttl:      duration,
+		expireAt: clock.Now().Add(duration),
+		keys:     map[string]bool{},
+	}
+	return r.nextLease, nil
+}
+
+func (r *MemoryRegistry) KeepAlive(ctx context.Context, lease LeaseID) (<-chan struct{}, error) {
+	r.mutex.Lock()
+	l, ok := r.leases[lease]
+	r.mutex.Unlock()
+	if !ok {
+		return nil, ErrLeaseNotFound
+	}
+	ticks := make(chan struct{}, 1)
+	interval := l.ttl / 3
+	if interval <= 0 {
+		interval = time.Second
+	}
+	go func() {
+		defer close(ticks)
+		ticker := clock.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if !r.renew(lease) {
+				return
+			}
+			select {
+			case ticks <- struct{}{}:
+			default:
+			}
+			select {
+			case <-ticker.C():
+			case <-ctx.Done():
+				return
+			case <-r.done:
+				return
+			}
+		}
+	}()
+	return ticks, nil
+}
+
+// Renew lease once, for leases kept alive by another process
+func (r *MemoryRegistry) KeepAliveOnce(ctx context.Context, lease LeaseID) error {
+	if !r.renew(lease) {
+		return ErrLeaseNotFound
+	}
+	return nil
+}
+
+func (r *MemoryRegistry) Watch(ctx context.Context, prefix string, revision int64) <-chan []*Event {
+	w := &memoryWatcher{
+		prefix: prefix,
+		notify: make(chan struct{}, 1),
+		out:    make(chan []*Event),
+	}
+	r.mutex.Lock()
+	if revision > 0 && revision <= r.revision {
+		if len(r.history) > 0 && r.history[0].Kv.ModRevision > revision {
+			// compacted
+			r.mutex.Unlock()
+			close(w.out)
+			return w.out
+		}
+		for _, event := range r.history {
+			if event.Kv.ModRevision >= revision {
+				w.push([]*Event{event})
+			}
+		}
+	}
+	r.watchers[w] = true
+	r.mutex.Unlock()
+	go func() {
+		w.run(ctx, r.done)
+		r.mutex.Lock()
+		delete(r.watchers, w)
+		r.mutex.Unlock()
+	}()
+	return w.out
+}
+
+func (r *MemoryRegistry) Done() <-chan struct{} {
+	return r.done
+}
+
+func (r *MemoryRegistry) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+	}
+	return nil
+}
+
+func (r *MemoryRegistry) put(key string, value []byte, lease LeaseID) *KeyValue {
+	r.revision++
+	kv := &KeyValue{Key: key, Value: append([]byte(nil), value...), ModRevision: r.revision}
+	if old, ok := r.kvs[key]; ok && old.lease != 0 && old.lease != lease {
+		if l, ok := r.leases[old.lease]; ok {
+			delete(l.keys, key)
+		}
+	}
+	if lease != 0 {
+		r.leases[lease].keys[key] = true
+	}
+	r.kvs[key] = &memoryEntry{kv: kv, lease: lease}
+	r.notify(&Event{Type: EventPut, Kv: kv})
+	return kv
+}
+
+func (r *MemoryRegistry) delete(key string) {
+	entry, ok := r.kvs[key]
+	if !ok {
+		return
+	}
+	if l, ok := r.leases[entry.lease]; ok {
+		delete(l.keys, key)
+	}
+	delete(r.kvs, key)
+	r.revision++
+	r.notify(&Event{Type: EventDelete, Kv: &KeyValue{Key: key, ModRevision: r.revision}})
+}
+
+func (r *MemoryRegistry) notify(event *Event) {
+	r.history = append(r.history, event)
+	if len(r.history) > memoryHistory {
+		r.history = r.history[len(r.history)-memoryHistory:]
+	}
+	for w := range r.watchers {
+		w.push([]*Event{event})
+	}
+}
+
+func (r *MemoryRegistry) renew(lease LeaseID) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	l, ok := r.leases[lease]
+	if ok {
+		l.expireAt = clock.Now().Add(l.ttl)
+	}
+	return ok
+}
+
+// leases expire on clock, checked in real time so advancing a fake clock
+// takes effect without ticking it
+func (r *MemoryRegistry) expireLoop() {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.expireLeases(clock.Now())
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// keys attached to expired leases are deleted
+func (r *MemoryRegistry) expireLeases(now time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for id, l := range r.leases {
+		if now.Before(l.expireAt) {
+			continue
+		}
+		delete(r.leases, id)
+		for key := range l.keys {
+			r.delete(key)
+		}
+	}
+}
+
+type memoryWatcher struct {
+	prefix  string
+	mutex   sync.Mutex
+	pending []*Event
+	notify  chan struct{}
+	out     chan []*Event
+}
+
+// never blocks the registry, events are queued until watcher reads them
+func (w *memoryWatcher) push(events []*Event) {
+	w.mutex.Lock()
+	for _, event := range events {
+		if strings.HasPrefix(event.Kv.Key, w.prefix) {
+			w.pending = append(w.pending, event)
+		}
+	}
+	w.mutex.Unlock()
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *memoryWatcher) run(ctx context.Context, done chan struct{}) {
+	defer close(w.out)
+	for {
+		select {
+		case <-w.notify:
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		}
+		w.mutex.Lock()
+		events := w.pending
+		w.pending = nil
+		w.mutex.Unlock()
+		if len(events) == 0 {
+			continue
+		}
+		select {
+		case w.out <- events:
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		}
+	}
+}
+
+func copyKv(kv *KeyValue) *KeyValue {
+	c := *kv
+	c.Value = append([]byte(nil), kv.Value...)
+	return &c
+}
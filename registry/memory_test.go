@@ -0,0 +1,103 @@
+package registry
+
+import (
+	"context"
+	"github.com/mafei198/gactor/clock"
+	"testing"
+	"time"
+)
+
+func TestMemoryCompareAndSwap(t *testing.T) {
+	r := NewMemoryRegistry()
+	defer r.Close()
+	ctx := context.Background()
+	kv, ok, err := r.CompareAndSwap(ctx, "a", 0, []byte("1"))
+	if err != nil || !ok {
+		t.Fatal("create failed: ", ok, err)
+	}
+	if _, ok, _ := r.CompareAndSwap(ctx, "a", 0, []byte("2")); ok {
+		t.Fatal("create existing key succeeded")
+	}
+	current, ok, _ := r.CompareAndSwap(ctx, "a", kv.ModRevision+1, []byte("2"))
+	if ok || string(current.Value) != "1" {
+		t.Fatal("stale revision succeeded: ", current)
+	}
+	if _, ok, _ := r.CompareAndSwap(ctx, "a", kv.ModRevision, []byte("2")); !ok {
+		t.Fatal("update failed")
+	}
+	if kv, _ := r.Get(ctx, "a"); string(kv.Value) != "2" {
+		t.Fatal("unexpected value: ", string(kv.Value))
+	}
+}
+
+func TestMemoryWatch(t *testing.T) {
+	r := NewMemoryRegistry()
+	defer r.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_ = r.Put(ctx, "p/a", []byte("1"), 0)
+	_, revision, _ := r.GetPrefix(ctx, "p/")
+	_ = r.Put(ctx, "p/b", []byte("2"), 0)
+	_ = r.Put(ctx, "q/c", []byte("3"), 0)
+	ch := r.Watch(ctx, "p/", revision+1)
+	_ = r.Delete(ctx, "p/a")
+	var events []*Event
+	for len(events) < 2 {
+		select {
+		case batch := <-ch:
+			events = append(events, batch...)
+		case <-time.After(time.Second):
+			t.Fatal("watch timeout: ", events)
+		}
+	}
+	if events[0].Type != EventPut || events[0].Kv.Key != "p/b" {
+		t.Fatal("unexpected event: ", events[0].Kv)
+	}
+	if events[1].Type != EventDelete || events[1].Kv.Key != "p/a" {
+		t.Fatal("unexpected event: ", events[1].Kv)
+	}
+}
+
+func TestMemoryLeaseExpire(t *testing.T) {
+	r := NewMemoryRegistry()
+	defer r.Close()
+	ctx := context.Background()
+	lease, _ := r.Grant(ctx, 1)
+	if err := r.Put(ctx, "node", []byte("1"), lease); err != nil {
+		t.Fatal(err)
+	}
+	r.expireLeases(time.Now().Add(2 * time.Second))
+	if kv, _ := r.Get(ctx, "node"); kv != nil {
+		t.Fatal("key of expired lease exists")
+	}
+	if err := r.Put(ctx, "node", []byte("1"), lease); err != ErrLeaseNotFound {
+		t.Fatal("put with expired lease: ", err)
+	}
+}
+
+func TestMemoryLeaseExpireByClock(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1500000000, 0))
+	clock.Set(fake)
+	defer clock.Set(clock.Real)
+	r := NewMemoryRegistry()
+	defer r.Close()
+	ctx := context.Background()
+	lease, _ := r.Grant(ctx, 2)
+	if err := r.Put(ctx, "node", []byte("1"), lease); err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(time.Second)
+	if err := r.KeepAliveOnce(ctx, lease); err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(1999 * time.Millisecond)
+	r.expireLeases(clock.Now())
+	if kv, _ := r.Get(ctx, "node"); kv == nil {
+		t.Fatal("key of renewed lease expired early")
+	}
+	fake.Advance(time.Millisecond)
+	r.expireLeases(clock.Now())
+	if kv, _ := r.Get(ctx, "node"); kv != nil {
+		t.Fatal("key of expired lease exists")
+	}
+}
@@ -0,0 +1,84 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package registry
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type LeaseID int64
+
+type KeyValue struct {
+	Key         string
+	Value       []byte
+	ModRevision int64
+}
+
+const (
+	EventPut = iota
+	EventDelete
+)
+
+type Event struct {
+	Type int
+	Kv   *KeyValue // Value is empty for EventDelete
+}
+
+// Cluster registry of metas, nodes, groups and topics
+type Registry interface {
+	// return nil if not exists
+	Get(ctx context.Context, key string) (*KeyValue, error)
+	// keys under prefix and the revision they are read at
+	GetPrefix(ctx context.Context, prefix string) ([]*KeyValue, int64, error)
+	// lease 0 for keys never expire
+	Put(ctx context.Context, key string, value []byte, lease LeaseID) error
+	Delete(ctx context.Context, key string) error
+	// Put value if ModRevision of key equals revision (0 for not exists),
+	// return the stored kv if succeeded, otherwise the current kv.
+	CompareAndSwap(ctx context.Context, key string, revision int64, value []byte) (*KeyValue, bool, error)
+	// ttl in seconds
+	Grant(ctx context.Context, ttl int64) (LeaseID, error)
+	// Keep lease alive until ctx done, a tick is sent on every renewal,
+	// channel is closed when lease is lost.
+	KeepAlive(ctx context.Context, lease LeaseID) (<-chan struct{}, error)
+	// Watch events under prefix from revision (0 for now), channel is closed
+	// on failure or events compacted, callers should reload then.
+	Watch(ctx context.Context, prefix string, revision int64) <-chan []*Event
+	// closed when registry is closed, watchers stop instead of watching again
+	Done() <-chan struct{}
+	Close() error
+}
+
+var ErrLeaseNotFound = errors.New("lease not found")
+
+var Current Registry
+
+func Set(r Registry) {
+	Current = r
+}
+
+// timeout of a single registry request
+var RequestTimeout = 5 * time.Second
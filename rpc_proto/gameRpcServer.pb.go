@@ -25,11 +25,13 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type StreamAgentMsg struct {
-	ReqId                int32    `protobuf:"varint,1,opt,name=ReqId,proto3" json:"ReqId,omitempty"`
+	ReqId                int64    `protobuf:"varint,1,opt,name=ReqId,proto3" json:"ReqId,omitempty"`
 	ReqType              int32    `protobuf:"varint,2,opt,name=ReqType,proto3" json:"ReqType,omitempty"`
 	FromActorId          string   `protobuf:"bytes,3,opt,name=FromActorId,proto3" json:"FromActorId,omitempty"`
 	ToActorId            string   `protobuf:"bytes,4,opt,name=ToActorId,proto3" json:"ToActorId,omitempty"`
 	Data                 []byte   `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`
+	Deadline             int64    `protobuf:"varint,6,opt,name=Deadline,proto3" json:"Deadline,omitempty"`
+	OriginNodeId         string   `protobuf:"bytes,7,opt,name=OriginNodeId,proto3" json:"OriginNodeId,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -60,7 +62,7 @@ func (m *StreamAgentMsg) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_StreamAgentMsg proto.InternalMessageInfo
 
-func (m *StreamAgentMsg) GetReqId() int32 {
+func (m *StreamAgentMsg) GetReqId() int64 {
 	if m != nil {
 		return m.ReqId
 	}
@@ -95,10 +97,27 @@ func (m *StreamAgentMsg) GetData() []byte {
 	return nil
 }
 
+func (m *StreamAgentMsg) GetDeadline() int64 {
+	if m != nil {
+		return m.Deadline
+	}
+	return 0
+}
+
+func (m *StreamAgentMsg) GetOriginNodeId() string {
+	if m != nil {
+		return m.OriginNodeId
+	}
+	return ""
+}
+
 type StreamAgentRsp struct {
-	ReqId                int32    `protobuf:"varint,1,opt,name=ReqId,proto3" json:"ReqId,omitempty"`
+	ReqId                int64    `protobuf:"varint,1,opt,name=ReqId,proto3" json:"ReqId,omitempty"`
 	FromActorId          string   `protobuf:"bytes,2,opt,name=FromActorId,proto3" json:"FromActorId,omitempty"`
 	Data                 []byte   `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	ErrCode              int32    `protobuf:"varint,4,opt,name=ErrCode,proto3" json:"ErrCode,omitempty"`
+	ErrMsg               string   `protobuf:"bytes,5,opt,name=ErrMsg,proto3" json:"ErrMsg,omitempty"`
+	OriginNodeId         string   `protobuf:"bytes,6,opt,name=OriginNodeId,proto3" json:"OriginNodeId,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -129,7 +148,7 @@ func (m *StreamAgentRsp) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_StreamAgentRsp proto.InternalMessageInfo
 
-func (m *StreamAgentRsp) GetReqId() int32 {
+func (m *StreamAgentRsp) GetReqId() int64 {
 	if m != nil {
 		return m.ReqId
 	}
@@ -150,6 +169,27 @@ func (m *StreamAgentRsp) GetData() []byte {
 	return nil
 }
 
+func (m *StreamAgentRsp) GetErrCode() int32 {
+	if m != nil {
+		return m.ErrCode
+	}
+	return 0
+}
+
+func (m *StreamAgentRsp) GetErrMsg() string {
+	if m != nil {
+		return m.ErrMsg
+	}
+	return ""
+}
+
+func (m *StreamAgentRsp) GetOriginNodeId() string {
+	if m != nil {
+		return m.OriginNodeId
+	}
+	return ""
+}
+
 type StartActorReq struct {
 	ActorId              string   `protobuf:"bytes,1,opt,name=actorId,proto3" json:"actorId,omitempty"`
 	Timeout              int64    `protobuf:"varint,2,opt,name=timeout,proto3" json:"timeout,omitempty"`
@@ -236,36 +276,355 @@ func (m *StartActorRsp) GetSuccess() bool {
 	return false
 }
 
+type MigrateActorReq struct {
+	ActorId              string   `protobuf:"bytes,1,opt,name=actorId,proto3" json:"actorId,omitempty"`
+	State                []byte   `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	Timeout              int64    `protobuf:"varint,3,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MigrateActorReq) Reset()         { *m = MigrateActorReq{} }
+func (m *MigrateActorReq) String() string { return proto.CompactTextString(m) }
+func (*MigrateActorReq) ProtoMessage()    {}
+func (*MigrateActorReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4747c30070216317, []int{4}
+}
+
+func (m *MigrateActorReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MigrateActorReq.Unmarshal(m, b)
+}
+func (m *MigrateActorReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MigrateActorReq.Marshal(b, m, deterministic)
+}
+func (m *MigrateActorReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MigrateActorReq.Merge(m, src)
+}
+func (m *MigrateActorReq) XXX_Size() int {
+	return xxx_messageInfo_MigrateActorReq.Size(m)
+}
+func (m *MigrateActorReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_MigrateActorReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MigrateActorReq proto.InternalMessageInfo
+
+func (m *MigrateActorReq) GetActorId() string {
+	if m != nil {
+		return m.ActorId
+	}
+	return ""
+}
+
+func (m *MigrateActorReq) GetState() []byte {
+	if m != nil {
+		return m.State
+	}
+	return nil
+}
+
+func (m *MigrateActorReq) GetTimeout() int64 {
+	if m != nil {
+		return m.Timeout
+	}
+	return 0
+}
+
+type MigrateActorRsp struct {
+	Success              bool     `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MigrateActorRsp) Reset()         { *m = MigrateActorRsp{} }
+func (m *MigrateActorRsp) String() string { return proto.CompactTextString(m) }
+func (*MigrateActorRsp) ProtoMessage()    {}
+func (*MigrateActorRsp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4747c30070216317, []int{5}
+}
+
+func (m *MigrateActorRsp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MigrateActorRsp.Unmarshal(m, b)
+}
+func (m *MigrateActorRsp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MigrateActorRsp.Marshal(b, m, deterministic)
+}
+func (m *MigrateActorRsp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MigrateActorRsp.Merge(m, src)
+}
+func (m *MigrateActorRsp) XXX_Size() int {
+	return xxx_messageInfo_MigrateActorRsp.Size(m)
+}
+func (m *MigrateActorRsp) XXX_DiscardUnknown() {
+	xxx_messageInfo_MigrateActorRsp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MigrateActorRsp proto.InternalMessageInfo
+
+func (m *MigrateActorRsp) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+type ActorDown struct {
+	ActorId              string   `protobuf:"bytes,1,opt,name=ActorId,proto3" json:"ActorId,omitempty"`
+	Reason               string   `protobuf:"bytes,2,opt,name=Reason,proto3" json:"Reason,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ActorDown) Reset()         { *m = ActorDown{} }
+func (m *ActorDown) String() string { return proto.CompactTextString(m) }
+func (*ActorDown) ProtoMessage()    {}
+func (*ActorDown) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4747c30070216317, []int{6}
+}
+
+func (m *ActorDown) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ActorDown.Unmarshal(m, b)
+}
+func (m *ActorDown) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ActorDown.Marshal(b, m, deterministic)
+}
+func (m *ActorDown) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ActorDown.Merge(m, src)
+}
+func (m *ActorDown) XXX_Size() int {
+	return xxx_messageInfo_ActorDown.Size(m)
+}
+func (m *ActorDown) XXX_DiscardUnknown() {
+	xxx_messageInfo_ActorDown.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ActorDown proto.InternalMessageInfo
+
+func (m *ActorDown) GetActorId() string {
+	if m != nil {
+		return m.ActorId
+	}
+	return ""
+}
+
+func (m *ActorDown) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+type MonitorActor struct {
+	WatcherId            string   `protobuf:"bytes,1,opt,name=WatcherId,proto3" json:"WatcherId,omitempty"`
+	TargetId             string   `protobuf:"bytes,2,opt,name=TargetId,proto3" json:"TargetId,omitempty"`
+	Link                 bool     `protobuf:"varint,3,opt,name=Link,proto3" json:"Link,omitempty"`
+	Remove               bool     `protobuf:"varint,4,opt,name=Remove,proto3" json:"Remove,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MonitorActor) Reset()         { *m = MonitorActor{} }
+func (m *MonitorActor) String() string { return proto.CompactTextString(m) }
+func (*MonitorActor) ProtoMessage()    {}
+func (*MonitorActor) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4747c30070216317, []int{7}
+}
+
+func (m *MonitorActor) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MonitorActor.Unmarshal(m, b)
+}
+func (m *MonitorActor) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MonitorActor.Marshal(b, m, deterministic)
+}
+func (m *MonitorActor) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MonitorActor.Merge(m, src)
+}
+func (m *MonitorActor) XXX_Size() int {
+	return xxx_messageInfo_MonitorActor.Size(m)
+}
+func (m *MonitorActor) XXX_DiscardUnknown() {
+	xxx_messageInfo_MonitorActor.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MonitorActor proto.InternalMessageInfo
+
+func (m *MonitorActor) GetWatcherId() string {
+	if m != nil {
+		return m.WatcherId
+	}
+	return ""
+}
+
+func (m *MonitorActor) GetTargetId() string {
+	if m != nil {
+		return m.TargetId
+	}
+	return ""
+}
+
+func (m *MonitorActor) GetLink() bool {
+	if m != nil {
+		return m.Link
+	}
+	return false
+}
+
+func (m *MonitorActor) GetRemove() bool {
+	if m != nil {
+		return m.Remove
+	}
+	return false
+}
+
+type BroadcastMsg struct {
+	ActorIds             []string `protobuf:"bytes,1,rep,name=ActorIds,proto3" json:"ActorIds,omitempty"`
+	Data                 []byte   `protobuf:"bytes,2,opt,name=Data,proto3" json:"Data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BroadcastMsg) Reset()         { *m = BroadcastMsg{} }
+func (m *BroadcastMsg) String() string { return proto.CompactTextString(m) }
+func (*BroadcastMsg) ProtoMessage()    {}
+func (*BroadcastMsg) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4747c30070216317, []int{8}
+}
+
+func (m *BroadcastMsg) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BroadcastMsg.Unmarshal(m, b)
+}
+func (m *BroadcastMsg) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BroadcastMsg.Marshal(b, m, deterministic)
+}
+func (m *BroadcastMsg) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BroadcastMsg.Merge(m, src)
+}
+func (m *BroadcastMsg) XXX_Size() int {
+	return xxx_messageInfo_BroadcastMsg.Size(m)
+}
+func (m *BroadcastMsg) XXX_DiscardUnknown() {
+	xxx_messageInfo_BroadcastMsg.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BroadcastMsg proto.InternalMessageInfo
+
+func (m *BroadcastMsg) GetActorIds() []string {
+	if m != nil {
+		return m.ActorIds
+	}
+	return nil
+}
+
+func (m *BroadcastMsg) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type PublishMsg struct {
+	Topic                string   `protobuf:"bytes,1,opt,name=Topic,proto3" json:"Topic,omitempty"`
+	Data                 []byte   `protobuf:"bytes,2,opt,name=Data,proto3" json:"Data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PublishMsg) Reset()         { *m = PublishMsg{} }
+func (m *PublishMsg) String() string { return proto.CompactTextString(m) }
+func (*PublishMsg) ProtoMessage()    {}
+func (*PublishMsg) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4747c30070216317, []int{9}
+}
+
+func (m *PublishMsg) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PublishMsg.Unmarshal(m, b)
+}
+func (m *PublishMsg) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PublishMsg.Marshal(b, m, deterministic)
+}
+func (m *PublishMsg) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PublishMsg.Merge(m, src)
+}
+func (m *PublishMsg) XXX_Size() int {
+	return xxx_messageInfo_PublishMsg.Size(m)
+}
+func (m *PublishMsg) XXX_DiscardUnknown() {
+	xxx_messageInfo_PublishMsg.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PublishMsg proto.InternalMessageInfo
+
+func (m *PublishMsg) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+func (m *PublishMsg) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*StreamAgentMsg)(nil), "StreamAgentMsg")
 	proto.RegisterType((*StreamAgentRsp)(nil), "StreamAgentRsp")
 	proto.RegisterType((*StartActorReq)(nil), "StartActorReq")
 	proto.RegisterType((*StartActorRsp)(nil), "StartActorRsp")
+	proto.RegisterType((*MigrateActorReq)(nil), "MigrateActorReq")
+	proto.RegisterType((*MigrateActorRsp)(nil), "MigrateActorRsp")
+	proto.RegisterType((*ActorDown)(nil), "ActorDown")
+	proto.RegisterType((*MonitorActor)(nil), "MonitorActor")
+	proto.RegisterType((*BroadcastMsg)(nil), "BroadcastMsg")
+	proto.RegisterType((*PublishMsg)(nil), "PublishMsg")
 }
 
 func init() { proto.RegisterFile("gameRpcServer.proto", fileDescriptor_4747c30070216317) }
 
 var fileDescriptor_4747c30070216317 = []byte{
-	// 295 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x92, 0xb1, 0x4e, 0xf3, 0x30,
-	0x10, 0xc7, 0x3f, 0xb7, 0xcd, 0x57, 0x72, 0xa5, 0x45, 0x32, 0x0c, 0x51, 0xc5, 0x10, 0x65, 0x0a,
-	0x4b, 0x84, 0xa8, 0x78, 0x80, 0x82, 0x04, 0xea, 0xc0, 0xe2, 0x74, 0x42, 0x2c, 0x26, 0x39, 0x45,
-	0x0c, 0xc1, 0x8e, 0xed, 0x22, 0xf1, 0x1e, 0xbc, 0x05, 0x2f, 0x89, 0x72, 0x60, 0x35, 0x06, 0x31,
-	0x30, 0x25, 0xff, 0xff, 0xdd, 0xfd, 0xee, 0xce, 0x36, 0x1c, 0x37, 0xb2, 0x45, 0xa1, 0xab, 0x12,
-	0xcd, 0x0b, 0x9a, 0x42, 0x1b, 0xe5, 0x54, 0xf6, 0xc6, 0x60, 0x51, 0x3a, 0x83, 0xb2, 0x5d, 0x37,
-	0xf8, 0xec, 0xee, 0x6c, 0xc3, 0x4f, 0x20, 0x12, 0xd8, 0x6d, 0xea, 0x84, 0xa5, 0x2c, 0x8f, 0xc4,
-	0xa7, 0xe0, 0x09, 0x4c, 0x05, 0x76, 0xdb, 0x57, 0x8d, 0xc9, 0x88, 0x7c, 0x2f, 0x79, 0x0a, 0xb3,
-	0x1b, 0xa3, 0xda, 0x75, 0xe5, 0x94, 0xd9, 0xd4, 0xc9, 0x38, 0x65, 0x79, 0x2c, 0x86, 0x16, 0x3f,
-	0x85, 0x78, 0xab, 0x7c, 0x7c, 0x42, 0xf1, 0xbd, 0xc1, 0x39, 0x4c, 0x6a, 0xe9, 0x64, 0x12, 0xa5,
-	0x2c, 0x3f, 0x14, 0xf4, 0x9f, 0x3d, 0x04, 0x53, 0x09, 0xab, 0x7f, 0x99, 0xea, 0x5b, 0xef, 0xd1,
-	0xcf, 0xde, 0x9e, 0x3e, 0x1e, 0xd0, 0xaf, 0x61, 0x5e, 0x3a, 0x69, 0x1c, 0xe5, 0x08, 0xec, 0xfa,
-	0xe5, 0xe4, 0x17, 0x82, 0x11, 0xc2, 0xcb, 0x3e, 0xe2, 0x9e, 0x5a, 0x54, 0x3b, 0x47, 0xf0, 0xb1,
-	0xf0, 0x32, 0x3b, 0x0b, 0x20, 0x56, 0xf7, 0xa9, 0x76, 0x57, 0x55, 0x68, 0x2d, 0x41, 0x0e, 0x84,
-	0x97, 0x17, 0xef, 0x0c, 0xe6, 0xb7, 0xc3, 0xc3, 0xe7, 0x2b, 0x88, 0x7b, 0x41, 0x2b, 0xf2, 0xa3,
-	0x22, 0xbc, 0x81, 0x65, 0x60, 0x08, 0xab, 0xb3, 0x7f, 0x39, 0x3b, 0x67, 0xfc, 0x12, 0x66, 0xe4,
-	0xfc, 0xb1, 0xac, 0x00, 0xd8, 0x0f, 0xca, 0x17, 0x45, 0xb0, 0xfa, 0x32, 0xd0, 0x7d, 0xcd, 0xd5,
-	0xf4, 0x3e, 0xa2, 0xb7, 0xf1, 0xf8, 0x9f, 0x3e, 0xab, 0x8f, 0x00, 0x00, 0x00, 0xff, 0xff, 0x96,
-	0xe1, 0x35, 0x72, 0x39, 0x02, 0x00, 0x00,
+	// 543 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x94, 0xdf, 0x6e, 0xd3, 0x30,
+	0x14, 0xc6, 0xf1, 0xb2, 0xf4, 0xcf, 0x59, 0xb6, 0x21, 0x33, 0xa1, 0xa8, 0xe2, 0xa2, 0xca, 0x55,
+	0x10, 0x52, 0x84, 0x18, 0x70, 0x07, 0x52, 0xb7, 0x0e, 0x54, 0x89, 0x02, 0x72, 0x2b, 0x21, 0xc1,
+	0x95, 0x97, 0x58, 0x59, 0xc4, 0x12, 0xa7, 0xb6, 0x3b, 0xc4, 0x6b, 0xf1, 0x2c, 0xbc, 0x00, 0x6f,
+	0x82, 0x7c, 0x92, 0xb4, 0x49, 0x37, 0x10, 0x5c, 0xd5, 0xbf, 0xe3, 0xe3, 0xe3, 0xef, 0xe4, 0x7c,
+	0x2e, 0x3c, 0x48, 0x79, 0x2e, 0x58, 0x19, 0x2f, 0x84, 0xba, 0x11, 0x2a, 0x2a, 0x95, 0x34, 0x32,
+	0xf8, 0x49, 0xe0, 0x68, 0x61, 0x94, 0xe0, 0xf9, 0x24, 0x15, 0x85, 0x99, 0xeb, 0x94, 0x9e, 0x80,
+	0xcb, 0xc4, 0x6a, 0x96, 0xf8, 0x64, 0x4c, 0x42, 0x87, 0x55, 0x40, 0x7d, 0xe8, 0x33, 0xb1, 0x5a,
+	0x7e, 0x2f, 0x85, 0xbf, 0x37, 0x26, 0xa1, 0xcb, 0x1a, 0xa4, 0x63, 0x38, 0x78, 0xa3, 0x64, 0x3e,
+	0x89, 0x8d, 0x54, 0xb3, 0xc4, 0x77, 0xc6, 0x24, 0x1c, 0xb2, 0x76, 0x88, 0x3e, 0x82, 0xe1, 0x52,
+	0x36, 0xfb, 0xfb, 0xb8, 0xbf, 0x0d, 0x50, 0x0a, 0xfb, 0x09, 0x37, 0xdc, 0x77, 0xc7, 0x24, 0xf4,
+	0x18, 0xae, 0xe9, 0x08, 0x06, 0x53, 0xc1, 0x93, 0xeb, 0xac, 0x10, 0x7e, 0x0f, 0x65, 0x6c, 0x98,
+	0x06, 0xe0, 0x7d, 0x50, 0x59, 0x9a, 0x15, 0xef, 0x65, 0x22, 0x66, 0x89, 0xdf, 0xc7, 0x82, 0x9d,
+	0x58, 0xf0, 0xa3, 0xdb, 0x16, 0xd3, 0xe5, 0x1f, 0xda, 0xda, 0x11, 0xbf, 0x77, 0x5b, 0x7c, 0x23,
+	0xcf, 0x69, 0xc9, 0xf3, 0xa1, 0x7f, 0xa1, 0xd4, 0xb9, 0x4c, 0x04, 0xb6, 0xe3, 0xb2, 0x06, 0xe9,
+	0x43, 0xe8, 0x5d, 0x28, 0x35, 0xd7, 0x29, 0xb6, 0x33, 0x64, 0x35, 0xdd, 0x12, 0xdd, 0xbb, 0x43,
+	0xf4, 0x39, 0x1c, 0x2e, 0x0c, 0x57, 0x06, 0x6f, 0x66, 0x62, 0x65, 0xaf, 0xe1, 0xb5, 0x30, 0x82,
+	0xf9, 0x0d, 0xda, 0x1d, 0x93, 0xe5, 0x42, 0xae, 0x0d, 0x4a, 0x76, 0x58, 0x83, 0xc1, 0xe3, 0x4e,
+	0x11, 0x5d, 0xda, 0x54, 0xbd, 0x8e, 0x63, 0xa1, 0x35, 0x16, 0x19, 0xb0, 0x06, 0x83, 0x2f, 0x70,
+	0x3c, 0xcf, 0x52, 0xc5, 0x8d, 0xf8, 0x87, 0x1b, 0x4f, 0xc0, 0xd5, 0x86, 0x9b, 0x6a, 0xfa, 0x1e,
+	0xab, 0xa0, 0xad, 0xc3, 0xe9, 0xea, 0x78, 0xb2, 0x53, 0xfc, 0xaf, 0x4a, 0x5e, 0xc1, 0x10, 0xb3,
+	0xa6, 0xf2, 0x5b, 0x61, 0xd3, 0x26, 0x5d, 0x0d, 0x35, 0xda, 0x8f, 0xcb, 0x04, 0xd7, 0xb2, 0xa8,
+	0xe7, 0x54, 0x53, 0x60, 0xc0, 0x9b, 0xcb, 0x22, 0x33, 0x52, 0x61, 0xa6, 0xf5, 0xdb, 0x27, 0x6e,
+	0xe2, 0x2b, 0xb1, 0xad, 0xb1, 0x0d, 0x58, 0x6f, 0x2d, 0xb9, 0x4a, 0x85, 0xd9, 0xcc, 0x7b, 0xc3,
+	0x76, 0xd8, 0xef, 0xb2, 0xe2, 0x2b, 0x36, 0x33, 0x60, 0xb8, 0xae, 0x6e, 0xcd, 0xe5, 0x4d, 0x35,
+	0xeb, 0x01, 0xab, 0x29, 0x78, 0x0d, 0xde, 0x99, 0x92, 0x3c, 0x89, 0xb9, 0xc6, 0x77, 0x33, 0x82,
+	0x41, 0x2d, 0xd4, 0xf6, 0xe7, 0xd8, 0xba, 0x0d, 0xdb, 0xba, 0x53, 0x6b, 0xa2, 0xea, 0xe3, 0xe1,
+	0x3a, 0x78, 0x09, 0xf0, 0x71, 0x7d, 0x79, 0x9d, 0xe9, 0xab, 0xfa, 0xd5, 0x2d, 0x65, 0x99, 0xc5,
+	0xb5, 0xde, 0x0a, 0xee, 0x3a, 0xf7, 0xec, 0x17, 0x81, 0xc3, 0xb7, 0xed, 0xa7, 0x4c, 0x4f, 0x61,
+	0x68, 0x01, 0xfd, 0x4e, 0x8f, 0xa3, 0xee, 0x7b, 0x1e, 0x75, 0x02, 0x4c, 0x97, 0xc1, 0xbd, 0x90,
+	0x3c, 0x25, 0xf4, 0x05, 0x1c, 0x60, 0xe4, 0x3f, 0x8f, 0x45, 0x00, 0x5b, 0x7f, 0xd1, 0xa3, 0xa8,
+	0xe3, 0xd8, 0x51, 0x87, 0xed, 0x19, 0xfa, 0x1c, 0xbc, 0xb6, 0x0f, 0xe8, 0xfd, 0x68, 0xc7, 0x73,
+	0xa3, 0x9d, 0x88, 0x3d, 0x75, 0xd6, 0xff, 0xec, 0xe2, 0xff, 0xd3, 0x65, 0x0f, 0x7f, 0x4e, 0x7f,
+	0x03, 0x00, 0x00, 0xff, 0xff, 0x03, 0x00, 0x68, 0x9d, 0x06, 0x45, 0xbd, 0x04, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -286,6 +645,8 @@ type GameRpcServerClient interface {
 	AgentStream(ctx context.Context, opts ...grpc.CallOption) (GameRpcServer_AgentStreamClient, error)
 	// Start Actor
 	StartActor(ctx context.Context, in *StartActorReq, opts ...grpc.CallOption) (*StartActorRsp, error)
+	// Migrate Actor with state
+	MigrateActor(ctx context.Context, in *MigrateActorReq, opts ...grpc.CallOption) (*MigrateActorRsp, error)
 }
 
 type gameRpcServerClient struct {
@@ -367,6 +728,15 @@ func (c *gameRpcServerClient) StartActor(ctx context.Context, in *StartActorReq,
 	return out, nil
 }
 
+func (c *gameRpcServerClient) MigrateActor(ctx context.Context, in *MigrateActorReq, opts ...grpc.CallOption) (*MigrateActorRsp, error) {
+	out := new(MigrateActorRsp)
+	err := c.cc.Invoke(ctx, "/GameRpcServer/MigrateActor", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // GameRpcServerServer is the server API for GameRpcServer service.
 type GameRpcServerServer interface {
 	// Rpc streaming
@@ -375,6 +745,8 @@ type GameRpcServerServer interface {
 	AgentStream(GameRpcServer_AgentStreamServer) error
 	// Start Actor
 	StartActor(context.Context, *StartActorReq) (*StartActorRsp, error)
+	// Migrate Actor with state
+	MigrateActor(context.Context, *MigrateActorReq) (*MigrateActorRsp, error)
 }
 
 // UnimplementedGameRpcServerServer can be embedded to have forward compatible implementations.
@@ -390,6 +762,9 @@ func (*UnimplementedGameRpcServerServer) AgentStream(srv GameRpcServer_AgentStre
 func (*UnimplementedGameRpcServerServer) StartActor(ctx context.Context, req *StartActorReq) (*StartActorRsp, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method StartActor not implemented")
 }
+func (*UnimplementedGameRpcServerServer) MigrateActor(ctx context.Context, req *MigrateActorReq) (*MigrateActorRsp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MigrateActor not implemented")
+}
 
 func RegisterGameRpcServerServer(s *grpc.Server, srv GameRpcServerServer) {
 	s.RegisterService(&_GameRpcServer_serviceDesc, srv)
@@ -465,6 +840,24 @@ func _GameRpcServer_StartActor_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _GameRpcServer_MigrateActor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MigrateActorReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GameRpcServerServer).MigrateActor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/GameRpcServer/MigrateActor",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GameRpcServerServer).MigrateActor(ctx, req.(*MigrateActorReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _GameRpcServer_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "GameRpcServer",
 	HandlerType: (*GameRpcServerServer)(nil),
@@ -473,6 +866,10 @@ var _GameRpcServer_serviceDesc = grpc.ServiceDesc{
 			MethodName: "StartActor",
 			Handler:    _GameRpcServer_StartActor_Handler,
 		},
+		{
+			MethodName: "MigrateActor",
+			Handler:    _GameRpcServer_MigrateActor_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
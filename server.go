@@ -24,34 +24,61 @@ THE SOFTWARE.
 package gactor
 
 import (
+	"errors"
 	"github.com/mafei198/gactor/actor"
+	"github.com/mafei198/gactor/cluster"
 	"github.com/mafei198/gactor/etcd"
 	"github.com/mafei198/gactor/etcd/agents"
+	"github.com/mafei198/gactor/registry"
 	"github.com/mafei198/goslib/logger"
 )
 
 var (
-	actorMgr = new(actor.Manager)
-	rpcMgr   = new(actor.RpcMgr)
+	actorMgr  = new(actor.Manager)
+	rpcMgr    = new(actor.RpcMgr)
+	nodeAgent *agents.NodeAgent
 )
 
 func Start() error {
-	if err := etcd.Start(); err != nil {
+	return StartWithOptions(DefaultOptions())
+}
+
+func StartWithOptions(opts *Options) error {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	opts = opts.normalize()
+	cluster.SetClusterName(opts.ClusterName)
+	if err := startRegistry(opts); err != nil {
 		return err
 	}
+	if opts.Storage != nil {
+		actor.SetStorage(opts.Storage)
+	}
+	if opts.MetaCacheTTL > 0 {
+		actor.MetaCacheTTL = opts.MetaCacheTTL
+	}
+	if opts.MetaCacheSize > 0 {
+		actor.MetaCacheSize = opts.MetaCacheSize
+	}
 	if err := actorMgr.Start(); err != nil {
 		return err
 	}
 
-	port, err := rpcMgr.Start()
+	port, err := rpcMgr.Start(opts.RpcListenHost, opts.RpcPort, opts.RpcPortMax)
 	if err != nil {
 		return err
 	}
+	if opts.AdvertisePort > 0 {
+		port = opts.AdvertisePort
+	}
 
-	nodeAgent, err := agents.NewNodeAgent(port)
+	nodeAgent, err = agents.NewNodeAgent(opts.Role, opts.AdvertiseHost, port)
 	if err != nil {
 		return err
 	}
+	nodeAgent.LeaseTTL = opts.LeaseTTL
+	nodeAgent.Node.Weight = opts.Weight
 	if err := nodeAgent.Start(); err != nil {
 		return err
 	}
@@ -60,9 +87,45 @@ func Start() error {
 	if err := actorAgent.Start(); err != nil {
 		return err
 	}
+
+	groupAgent := agents.NewGroupAgent()
+	if err := groupAgent.Start(); err != nil {
+		return err
+	}
+
+	topicAgent := agents.NewTopicAgent()
+	if err := topicAgent.Start(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func startRegistry(opts *Options) error {
+	registry.RequestTimeout = opts.RequestTimeout
+	if opts.Registry != nil {
+		registry.Set(opts.Registry)
+		return nil
+	}
+	if err := etcd.StartWithConfig(opts.etcdConfig()); err != nil {
+		return err
+	}
+	registry.Set(registry.NewEtcdRegistry(etcd.Client))
 	return nil
 }
 
+// Drain moves actors off this node before shutdown: the node is marked
+// draining so no actor is placed on it, then actors are stopped category by
+// category and dispatched to other nodes, warmUp starts them there at once.
+func Drain(warmUp bool, categories ...string) error {
+	if nodeAgent == nil {
+		return errors.New("gactor not started")
+	}
+	if err := nodeAgent.Drain(); err != nil {
+		return err
+	}
+	return actorMgr.Drain(warmUp, categories...)
+}
+
 func Stop() {
 	if err := actorMgr.Stop(); err != nil {
 		logger.ERR("stop actorMgr failed: ", err)
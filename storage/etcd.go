@@ -0,0 +1,68 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package storage
+
+import (
+	"context"
+	"github.com/mafei198/gactor/cluster"
+	"github.com/mafei198/gactor/registry"
+)
+
+// Store snapshots in registry under cluster.SnapshotPrefix()+Prefix, etcd
+// limits value size (1.5MB by default), so it suits small states.
+type EtcdStorage struct {
+	Prefix string
+}
+
+func NewEtcdStorage(prefix string) *EtcdStorage {
+	return &EtcdStorage{Prefix: prefix}
+}
+
+func (s *EtcdStorage) Shared() bool { return true }
+
+func (s *EtcdStorage) Load(actorId string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), registry.RequestTimeout)
+	defer cancel()
+	kv, err := registry.Current.Get(ctx, s.key(actorId))
+	if err != nil || kv == nil {
+		return nil, err
+	}
+	return kv.Value, nil
+}
+
+func (s *EtcdStorage) Save(actorId string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), registry.RequestTimeout)
+	defer cancel()
+	return registry.Current.Put(ctx, s.key(actorId), data, 0)
+}
+
+func (s *EtcdStorage) Delete(actorId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), registry.RequestTimeout)
+	defer cancel()
+	return registry.Current.Delete(ctx, s.key(actorId))
+}
+
+func (s *EtcdStorage) key(actorId string) string {
+	return cluster.SnapshotPrefix() + s.Prefix + actorId
+}
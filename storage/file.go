@@ -0,0 +1,85 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2018 SavinMax. All rights reserved.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package storage
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// One file per actor under Dir, set SharedDir if Dir is mounted by every node
+type FileStorage struct {
+	Dir       string
+	SharedDir bool
+}
+
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStorage{Dir: dir}, nil
+}
+
+func (s *FileStorage) Shared() bool { return s.SharedDir }
+
+func (s *FileStorage) Load(actorId string) ([]byte, error) {
+	data, err := ioutil.ReadFile(s.path(actorId))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// write to temp file and rename, never leaves a partial snapshot
+func (s *FileStorage) Save(actorId string, data []byte) error {
+	tmp, err := ioutil.TempFile(s.Dir, ".snapshot-")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(actorId))
+}
+
+func (s *FileStorage) Delete(actorId string) error {
+	err := os.Remove(s.path(actorId))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileStorage) path(actorId string) string {
+	return filepath.Join(s.Dir, url.PathEscape(actorId)+".snapshot")
+}
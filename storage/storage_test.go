@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"github.com/mafei198/gactor/cluster"
+	"github.com/mafei198/gactor/registry"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func testRoundTrip(t *testing.T, s Storage) {
+	actorId := "Actor:test/1"
+	if data, err := s.Load(actorId); err != nil || data != nil {
+		t.Fatal("load missing: ", data, err)
+	}
+	for _, state := range [][]byte{[]byte("v1"), []byte("v2")} {
+		if err := s.Save(actorId, state); err != nil {
+			t.Fatal(err)
+		}
+		data, err := s.Load(actorId)
+		if err != nil || !bytes.Equal(data, state) {
+			t.Fatal("load: ", string(data), err)
+		}
+	}
+	if err := s.Delete(actorId); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := s.Load(actorId); err != nil || data != nil {
+		t.Fatal("load deleted: ", data, err)
+	}
+	if err := s.Delete(actorId); err != nil {
+		t.Fatal("delete missing: ", err)
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	s := NewMemoryStorage()
+	testRoundTrip(t, s)
+
+	// saved copy isn't changed by caller
+	data := []byte("state")
+	_ = s.Save("Actor:test", data)
+	data[0] = 'X'
+	if loaded, _ := s.Load("Actor:test"); string(loaded) != "state" {
+		t.Fatal("saved state changed: ", string(loaded))
+	}
+	if s.Shared() {
+		t.Fatal("memory storage is not shared")
+	}
+}
+
+func TestFileStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRoundTrip(t, s)
+
+	// reopened storage reads saved states
+	_ = s.Save("Actor:test", []byte("state"))
+	reopened, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded, _ := reopened.Load("Actor:test"); string(loaded) != "state" {
+		t.Fatal("reopened: ", string(loaded))
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatal("temp files left: ", len(files))
+	}
+}
+
+func TestEtcdStorage(t *testing.T) {
+	r := registry.NewMemoryRegistry()
+	defer r.Close()
+	registry.Set(r)
+	cluster.SetClusterName("test")
+	defer cluster.SetClusterName("")
+	s := NewEtcdStorage("players/")
+	testRoundTrip(t, s)
+
+	_ = s.Save("Actor:test", []byte("state"))
+	if kv, _ := r.Get(context.Background(), "/test/v1/snapshots/players/Actor:test"); kv == nil || string(kv.Value) != "state" {
+		t.Fatal("snapshot not under cluster root: ", kv)
+	}
+}